@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/misc"
+)
+
+// Minimum time between edits of the progress message of a backfill
+const BACKFILL_PROGRESS_INTERVAL = 5 * time.Second
+
+// Maximum number of matches listed by a dry run
+const BACKFILL_MATCHES_SHOWN = 20
+
+// Backfills take options rather than subcommands, so they get their own command
+func registerBackfill() error {
+    // Add signature
+    sig := &discordgo.ApplicationCommand{
+        Name: "redpin-backfill",
+        Description: "Pin messages from history that have enough reactions",
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "channel",
+                Description: "Channel to scan; scans every channel if not given",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: []discordgo.ChannelType{
+                    discordgo.ChannelTypeGuildText,
+                    discordgo.ChannelTypeGuildNews,
+                },
+            },
+            {
+                Name: "from",
+                Description: "Only scan messages sent on or after this date (YYYY-MM-DD)",
+                Type: discordgo.ApplicationCommandOptionString,
+            },
+            {
+                Name: "to",
+                Description: "Only scan messages sent on or before this date (YYYY-MM-DD)",
+                Type: discordgo.ApplicationCommandOptionString,
+            },
+            {
+                Name: "dryrun",
+                Description: "List the messages that would be pinned without pinning them",
+                Type: discordgo.ApplicationCommandOptionBoolean,
+            },
+        },
+        DefaultMemberPermissions: &permission,
+        DMPermission: &dmPermission,
+    }
+    signatures = append(signatures, sig)
+    handlers[sig.Name] = make(map[string]func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate))
+
+    // Register command
+    command_backfill.registerOptions()
+    index += 1
+
+    return nil
+}
+
+var command_backfill = Command{
+    metadata: nil,
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        var channels []string
+        var after, before time.Time
+        dry_run := false
+
+        for _, opt := range i.ApplicationCommandData().Options {
+            var err error
+            switch opt.Name {
+                case "channel":
+                    channels = append(channels, opt.ChannelValue(discord).ID)
+                case "from":
+                    after, err = time.Parse(time.DateOnly, strings.TrimSpace(opt.StringValue()))
+                case "to":
+                    before, err = time.Parse(time.DateOnly, strings.TrimSpace(opt.StringValue()))
+                    before = before.Add(24 * time.Hour - time.Nanosecond)
+                case "dryrun":
+                    dry_run = opt.BoolValue()
+            }
+            if err != nil {
+                respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: ":x:  Dates must be written as YYYY-MM-DD" })
+                return
+            }
+        }
+
+        // Backfills can outlast the interaction, so progress is shown in a regular message
+        respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: "Started backfill" })
+        embed := LoadingEmbed("Backfilling...")
+        msg, err := discord.ChannelMessageSendEmbed(i.ChannelID, embed)
+        if err != nil {
+            log.Printf("Failed to send backfill progress message: %v", err)
+        }
+        update := func() {
+            if msg != nil {
+                discord.ChannelMessageEditEmbed(msg.ChannelID, msg.ID, embed)
+            }
+        }
+
+        last := time.Now()
+        p, err := misc.Backfill(discord, i.GuildID, channels, after, before, dry_run, func(p *misc.BackfillProgress) {
+            if time.Since(last) < BACKFILL_PROGRESS_INTERVAL {
+                return
+            }
+            last = time.Now()
+            embed.Description = fmt.Sprintf("Scanning <#%s> (%d/%d channels)\nScanned %d messages, found %d to pin",
+                p.Channel, p.ChannelsDone + 1, p.ChannelsTotal, p.Scanned, len(p.Matches))
+            update()
+        })
+        if err != nil {
+            embed.Title = ":x:  Failed to backfill"
+            embed.Description = err.Error()
+            update()
+            return
+        }
+
+        // Respond with results
+        embed.Title = fmt.Sprintf("Backfill queued %d pins", len(p.Matches))
+        if dry_run {
+            embed.Title = fmt.Sprintf("Backfill would pin %d messages", len(p.Matches))
+        }
+        embed.Description = fmt.Sprintf("Scanned %d messages in %d channels", p.Scanned, p.ChannelsTotal)
+        if dry_run && len(p.Matches) > 0 {
+            var list strings.Builder
+            for n, match := range p.Matches {
+                if n == BACKFILL_MATCHES_SHOWN {
+                    list.WriteString(fmt.Sprintf("* ...and %d more\n", len(p.Matches) - n))
+                    break
+                }
+                link := misc.GetMessageLink(i.GuildID, match.Message.ChannelID, match.Message.ID)
+                if match.Board != "" {
+                    link += " (board " + match.Board + ")"
+                }
+                list.WriteString("* " + link + "\n")
+            }
+            embed.Description += "\n" + list.String()
+        }
+        update()
+    },
+}
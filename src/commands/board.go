@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+	"github.com/jadc/redpin/misc"
+)
+
+// Discord does not allow subcommands next to the options of /redpin, so boards get their own command
+func registerBoard() error {
+    // Add signature
+    sig := &discordgo.ApplicationCommand{
+        Name: "redpin-board",
+        Description: "Manage additional boards, each with its own channel, emojis, threshold and statistics",
+        Options: []*discordgo.ApplicationCommandOption{},
+        DefaultMemberPermissions: &permission,
+        DMPermission: &dmPermission,
+    }
+    signatures = append(signatures, sig)
+    handlers[sig.Name] = make(map[string]func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate))
+
+    // Register all subcommands
+    command_board_set.register()
+    command_board_view.register()
+    command_board_remove.register()
+    index += 1
+
+    return nil
+}
+
+var command_board_set_threshold_min = float64(1)
+var command_board_set = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "set",
+        Description: "Create a board, or change the settings of an existing one",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "name",
+                Description: "Name of the board",
+                Type: discordgo.ApplicationCommandOptionString,
+                MaxLength: 32,
+                Required: true,
+            },
+            {
+                Name: "channel",
+                Description: "Set which channel to send pins of this board to",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: []discordgo.ChannelType{
+                    discordgo.ChannelTypeGuildText,
+                    discordgo.ChannelTypeGuildForum,
+                },
+            },
+            {
+                Name: "threshold",
+                Description: "Set the minimum number of reactions required to pin a message to this board",
+                Type: discordgo.ApplicationCommandOptionInteger,
+                MinValue: &command_board_set_threshold_min,
+            },
+            {
+                Name: "emoji",
+                Description: "Set which emojis pin messages to this board; add *weight or =threshold after emojis",
+                Type: discordgo.ApplicationCommandOptionString,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Find the board with the given name, creating it if it doesn't exist
+        var name string
+        for _, opt := range i.ApplicationCommandData().Options[option].Options {
+            if opt.Name == "name" {
+                name = strings.TrimSpace(opt.StringValue())
+            }
+        }
+        if name == "" {
+            respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: ":x:  Board name cannot be empty" })
+            return
+        }
+        b, ok := c.Boards[name]
+        if !ok {
+            b = &database.Board{ Threshold: c.Threshold, Allowlist: make(database.Allowlist) }
+        }
+
+        // Write each given setting to the board
+        for _, opt := range i.ApplicationCommandData().Options[option].Options {
+            switch opt.Name {
+                case "channel":
+                    b.Channel = opt.ChannelValue(discord).ID
+                case "threshold":
+                    b.Threshold = int(opt.IntValue())
+                case "emoji":
+                    b.Allowlist = misc.ParseEmojiRules(opt.StringValue())
+            }
+        }
+
+        // A board without a channel cannot receive pins
+        if b.Channel == "" {
+            respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: ":x:  New boards need a channel" })
+            return
+        }
+
+        // Save changes to config
+        c.Boards[name] = b
+        err := db.SaveConfig(i.GuildID, c)
+        if err != nil {
+            log.Printf("Failed to save config: %v", err)
+            return
+        }
+
+        // Respond with success
+        embed := formatBoard(discord, i.GuildID, c, name)
+        embed.Title = "Set board " + name
+        respondEmbed(discord, i, embed)
+    },
+}
+
+var command_board_view = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "view",
+        Description: "List every board",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        if len(c.Boards) == 0 {
+            respondEmbed(discord, i, &discordgo.MessageEmbed{
+                Title: "Boards",
+                Description: "There are no boards other than the default one",
+            })
+            return
+        }
+
+        embed := &discordgo.MessageEmbed{ Title: "Boards" }
+        for _, name := range c.BoardNames()[1:] {
+            b := formatBoard(discord, i.GuildID, c, name)
+            embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+                Name: name,
+                Value: b.Description,
+            })
+        }
+        respondEmbed(discord, i, embed)
+    },
+}
+
+var command_board_remove = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "remove",
+        Description: "Remove a board; existing pins and statistics are kept",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "name",
+                Description: "Name of the board",
+                Type: discordgo.ApplicationCommandOptionString,
+                Required: true,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        name := strings.TrimSpace(i.ApplicationCommandData().Options[option].Options[0].StringValue())
+
+        // Write changes to config and save it
+        if _, ok := c.Boards[name]; !ok {
+            respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: ":x:  No board named " + name })
+            return
+        }
+        delete(c.Boards, name)
+        err := db.SaveConfig(i.GuildID, c)
+        if err != nil {
+            log.Printf("Failed to save config: %v", err)
+            return
+        }
+
+        // Respond with success
+        respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: "Removed board " + name })
+    },
+}
+
+// formatBoard returns an embed describing the settings of a board
+func formatBoard(discord *discordgo.Session, guild_id string, c *database.Config, name string) *discordgo.MessageEmbed {
+    bc, _ := c.Board(name)
+    return &discordgo.MessageEmbed{
+        Description: fmt.Sprintf("Channel: <#%s>\nThreshold: %d\nEmojis:\n%s", bc.Channel, bc.Threshold, formatAllowlist(discord, guild_id, bc)),
+    }
+}
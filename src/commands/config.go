@@ -6,6 +6,9 @@ import (
 	"github.com/jadc/redpin/misc"
 	"log"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"encoding/json"
 )
 
@@ -24,16 +27,37 @@ func registerConfig() error {
     // Register all subcommands
     command_config_main.register()
     command_config_channel.register()
+    command_config_thread.register()
     command_config_threshold.register()
+    command_config_countmode.register()
     command_config_nsfw.register()
     command_config_selfpin.register()
     command_config_replydepth.register()
+    command_config_context.register()
+    command_config_contextwindow.register()
     command_config_emoji.register()
+    command_config_retract.register()
+    command_config_mirror.register()
+    command_config_catchup.register()
+    command_config_nativepins.register()
+    command_config_nativeunpin.register()
+    command_config_render.register()
+    command_config_layout.register()
+    command_config_header.register()
+    command_config_footer.register()
+    command_config_username.register()
+    command_config_forumtags.register()
     index += 1
 
     return nil
 }
 
+// Maximum length of the value of an embed field
+const MAX_FIELD_LENGTH = 1024
+
+// Maximum length of the description of an embed
+const MAX_DESCRIPTION_LENGTH = 4096
+
 // Command to view current config for the guild
 var command_config_main = Command{
     metadata: nil,
@@ -48,23 +72,35 @@ var command_config_main = Command{
             return
         }
 
-        // Respond with success
-        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+        // Respond with the config as a file, as it outgrows the limits of an embed
+        err = discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
             Type: discordgo.InteractionResponseChannelMessageWithSource,
             Data: &discordgo.InteractionResponseData{
                 Embeds: []*discordgo.MessageEmbed{
                     {
+                        Title: "Current Config",
+                        Description: "The full config is attached",
                         Fields: []*discordgo.MessageEmbedField{
                             {
-                                Name: "Current Config",
-                                Value: "```json\n" + string(j) + "\n```",
+                                Name: "Emojis",
+                                Value: truncate(formatAllowlist(discord, i.GuildID, c), MAX_FIELD_LENGTH),
                             },
                         },
                     },
                 },
+                Files: []*discordgo.File{
+                    {
+                        Name: "config.json",
+                        ContentType: "application/json",
+                        Reader: strings.NewReader(string(j)),
+                    },
+                },
                 Flags:   discordgo.MessageFlagsEphemeral,
             },
         })
+        if err != nil {
+            log.Printf("Failed to respond with config: %v", err)
+        }
     },
 }
 
@@ -75,6 +111,7 @@ var command_config_channel = Command{
         Type: discordgo.ApplicationCommandOptionChannel,
         ChannelTypes: []discordgo.ChannelType{
             discordgo.ChannelTypeGuildText,
+            discordgo.ChannelTypeGuildForum,
         },
     },
     handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
@@ -86,6 +123,9 @@ var command_config_channel = Command{
         new_value := i.ApplicationCommandData().Options[option].ChannelValue(discord).ID
         if c.Channel != new_value {
             c.Channel = new_value
+
+            // Threads belong to the previous pin channel
+            c.Thread = ""
             err := db.SaveConfig(i.GuildID, c)
             if err != nil {
                 log.Printf("Failed to save config: %v", err)
@@ -106,6 +146,48 @@ var command_config_channel = Command{
     },
 }
 
+var command_config_thread = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "thread",
+        Description: "Set which thread of the pin channel to send pins to (set the channel again to undo)",
+        Type: discordgo.ApplicationCommandOptionChannel,
+        ChannelTypes: []discordgo.ChannelType{
+            discordgo.ChannelTypeGuildPublicThread,
+            discordgo.ChannelTypeGuildPrivateThread,
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Only threads of the pin channel can receive its pins
+        thread := i.ApplicationCommandData().Options[option].ChannelValue(discord)
+        resp := fmt.Sprintf("Set pin thread to <#%s>", thread.ID)
+        if thread.ParentID != c.Channel {
+            resp = fmt.Sprintf("<#%s> is not a thread of the pin channel", thread.ID)
+        } else if c.Thread != thread.ID {
+            c.Thread = thread.ID
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
 var command_config_threshold_min = float64(1)
 var command_config_threshold = Command{
     metadata: &discordgo.ApplicationCommandOption{
@@ -143,6 +225,51 @@ var command_config_threshold = Command{
     },
 }
 
+var command_config_countmode = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "countmode",
+        Description: "Set whether each emoji must reach the threshold, or the sum of all allowed emojis",
+        Type: discordgo.ApplicationCommandOptionString,
+        Choices: []*discordgo.ApplicationCommandOptionChoice{
+            { Name: "each", Value: database.COUNT_EACH },
+            { Name: "sum", Value: database.COUNT_SUM },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := i.ApplicationCommandData().Options[option].StringValue()
+        if c.CountMode != new_value {
+            c.CountMode = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        var resp string
+        if c.CountMode == database.COUNT_SUM {
+            resp = "Messages are now pinned when enough members react with any allowed emojis"
+        } else {
+            resp = "Messages are now pinned when any one emoji reaches the threshold"
+        }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
 var command_config_nsfw = Command{
     metadata: &discordgo.ApplicationCommandOption{
         Name: "nsfw",
@@ -263,10 +390,86 @@ var command_config_replydepth = Command{
     },
 }
 
+var command_config_context_min = float64(0)
+var command_config_context = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "context",
+        Description: "Set how many preceding messages are quoted above pins as context (set to 0 to disable)",
+        Type: discordgo.ApplicationCommandOptionInteger,
+        MinValue: &command_config_context_min,
+        MaxValue: 10,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := int(i.ApplicationCommandData().Options[option].IntValue())
+        if c.ContextDepth != new_value {
+            c.ContextDepth = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: fmt.Sprintf("Set context depth to %d", new_value) },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+var command_config_contextwindow_min = float64(0)
+var command_config_contextwindow = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "contextwindow",
+        Description: "Set how many minutes before a pinned message its context may be from (set to 0 for no limit)",
+        Type: discordgo.ApplicationCommandOptionInteger,
+        MinValue: &command_config_contextwindow_min,
+        MaxValue: 1440,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := int(i.ApplicationCommandData().Options[option].IntValue())
+        if c.ContextWindow != new_value {
+            c.ContextWindow = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: fmt.Sprintf("Set context window to %d minutes", new_value) },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
 var command_config_emoji = Command{
     metadata: &discordgo.ApplicationCommandOption{
         Name: "emoji",
-        Description: "Customize which emojis can pin messages; write 'all' to allow any emoji",
+        Description: "Customize which emojis can pin; add *weight or =threshold after emojis; write 'all' for any emoji",
         Type: discordgo.ApplicationCommandOptionString,
     },
     handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
@@ -276,27 +479,358 @@ var command_config_emoji = Command{
 
         // Write changes to config and save it
         input := i.ApplicationCommandData().Options[option].StringValue()
-        emojis := misc.ExtractEmojis(input)
+        rules := misc.ParseEmojiRules(input)
 
         // If no emojis are given, clear the allow list
         var resp string
-        if len(emojis) == 0 {
-            c.Allowlist = make(map[string]struct{})
+        if len(rules) == 0 {
+            c.Allowlist = make(database.Allowlist)
             resp = "Allowlist was cleared, any emoji can now pin messages"
         } else {
-            for _, emoji := range emojis {
-                c.Allowlist[emoji] = struct{}{}
+            for emoji, rule := range rules {
+                c.Allowlist[emoji] = rule
             }
+            resp = "Allowlist was updated with the given emojis"
+        }
 
+        err := db.SaveConfig(i.GuildID, c)
+        if err != nil {
+            log.Printf("Failed to save config: %v", err)
+            return
+        }
+
+        // Respond with success
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    {
+                        Title: resp,
+                        Description: formatAllowlist(discord, i.GuildID, c),
+                    },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+// formatAllowlist returns a human readable list of the emojis that can pin messages, and their rules
+func formatAllowlist(discord *discordgo.Session, guild_id string, c *database.Config) string {
+    if len(c.Allowlist) == 0 {
+        return "Any emoji"
+    }
+
+    emojis := slices.Sorted(maps.Keys(c.Allowlist))
+
+    var list strings.Builder
+    for _, emoji := range emojis {
+        list.WriteString("* " + misc.FormatEmoji(discord, guild_id, emoji))
+        if w := c.Allowlist.Weight(emoji); w != 1 {
+            list.WriteString(fmt.Sprintf(", worth %d points", w))
+        }
+        list.WriteString(fmt.Sprintf(", pins at %d\n", c.Allowlist.Threshold(emoji, c.Threshold)))
+    }
+    return list.String()
+}
+
+var command_config_retract_min = float64(0)
+var command_config_retract = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "retract",
+        Description: "Set how many minutes a pin is retracted for if it drops below the threshold (set to 0 to disable)",
+        Type: discordgo.ApplicationCommandOptionInteger,
+        MinValue: &command_config_retract_min,
+        MaxValue: 10080,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := int(i.ApplicationCommandData().Options[option].IntValue())
+        if c.RetractWindow != new_value {
+            c.RetractWindow = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        var resp string
+        if c.RetractWindow > 0 {
+            resp = fmt.Sprintf("Pins are now retracted if they drop below the threshold within %d minutes", new_value)
+        } else {
+            resp = "Pins are no longer retracted"
+        }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+var command_config_mirror = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "mirror",
+        Description: "Set whether edits to pinned messages are mirrored to their pins",
+        Type: discordgo.ApplicationCommandOptionBoolean,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := i.ApplicationCommandData().Options[option].BoolValue()
+        if c.Mirror != new_value {
+            c.Mirror = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        var resp string
+        if c.Mirror {
+            resp = "Pins will now be updated when their message is edited"
+        } else {
+            resp = "Pins are now snapshots of their message at the time of pinning"
+        }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+var command_config_catchup_min = float64(0)
+var command_config_catchup = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "catchup",
+        Description: "Set how many hours of messages are checked for missed pins after downtime (set to 0 to disable)",
+        Type: discordgo.ApplicationCommandOptionInteger,
+        MinValue: &command_config_catchup_min,
+        MaxValue: 168,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := int(i.ApplicationCommandData().Options[option].IntValue())
+        if c.CatchupWindow != new_value {
+            c.CatchupWindow = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        var resp string
+        if c.CatchupWindow > 0 {
+            resp = fmt.Sprintf("Messages from %d hours before downtime are now checked for missed pins", new_value)
+        } else {
+            resp = "Missed pins are no longer caught up on after downtime"
+        }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+var command_config_nativepins = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "nativepins",
+        Description: "Set whether messages pinned natively are ignored, copied, or copied and then unpinned",
+        Type: discordgo.ApplicationCommandOptionString,
+        Choices: []*discordgo.ApplicationCommandOptionChoice{
+            { Name: "ignore", Value: database.NATIVE_IGNORE },
+            { Name: "mirror", Value: database.NATIVE_MIRROR },
+            { Name: "mirror and unpin", Value: database.NATIVE_UNPIN },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := i.ApplicationCommandData().Options[option].StringValue()
+        if c.NativePins != new_value {
+            c.NativePins = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        var resp string
+        switch c.NativePins {
+            case database.NATIVE_IGNORE:
+                resp = "Messages pinned natively are now ignored"
+            case database.NATIVE_UNPIN:
+                resp = "Messages pinned natively are now copied to the pin channel, then unpinned"
+            default:
+                resp = "Messages pinned natively are now copied to the pin channel"
+        }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+var command_config_nativeunpin = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "nativeunpin",
+        Description: "Set whether natively unpinning a message removes its copy from the pin channel",
+        Type: discordgo.ApplicationCommandOptionBoolean,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := i.ApplicationCommandData().Options[option].BoolValue()
+        if c.NativeUnpin != new_value {
+            c.NativeUnpin = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        var resp string
+        if c.NativeUnpin {
+            resp = "Natively unpinning a message now removes its copy"
+        } else {
+            resp = "Natively unpinning a message now keeps its copy"
+        }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+var command_config_render = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "render",
+        Description: "Set whether pins are sent as copies through webhooks, or as embeds by the bot itself",
+        Type: discordgo.ApplicationCommandOptionString,
+        Choices: []*discordgo.ApplicationCommandOptionChoice{
+            { Name: "webhook", Value: database.RENDER_WEBHOOK },
+            { Name: "embed", Value: database.RENDER_EMBED },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := i.ApplicationCommandData().Options[option].StringValue()
+        if c.Render != new_value {
+            c.Render = new_value
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        var resp string
+        if c.Render == database.RENDER_EMBED {
+            resp = "Pins are now sent as embeds"
+        } else {
+            resp = "Pins are now sent as copies through webhooks"
+        }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    { Title: resp },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
+
+var command_config_layout = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "layout",
+        Description: "Set whether webhook pins are split into several messages, or sent as one compact message",
+        Type: discordgo.ApplicationCommandOptionString,
+        Choices: []*discordgo.ApplicationCommandOptionChoice{
+            { Name: "classic", Value: database.LAYOUT_CLASSIC },
+            { Name: "compact", Value: database.LAYOUT_COMPACT },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        new_value := i.ApplicationCommandData().Options[option].StringValue()
+        if c.Layout != new_value {
+            c.Layout = new_value
             err := db.SaveConfig(i.GuildID, c)
             if err != nil {
                 log.Printf("Failed to save config: %v", err)
                 return
             }
-            resp = "Allowlist was updated with the given emojis"
         }
 
         // Respond with success
+        var resp string
+        if c.Layout == database.LAYOUT_COMPACT {
+            resp = "Pins are now sent as one compact message"
+        } else {
+            resp = "Pins are now sent as a copy of the message between a header and footer"
+        }
         discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
             Type: discordgo.InteractionResponseChannelMessageWithSource,
             Data: &discordgo.InteractionResponseData{
@@ -308,3 +842,135 @@ var command_config_emoji = Command{
         })
     },
 }
+
+var command_config_header = templateCommand("header", "Set the template of the header above pins of replies (set to default to restore it)",
+    func(t *database.Templates) *string { return &t.Header }, misc.DEFAULT_HEADER)
+var command_config_footer = templateCommand("footer", "Set the template of the footer below pins (set to default to restore it)",
+    func(t *database.Templates) *string { return &t.Footer }, misc.DEFAULT_FOOTER)
+var command_config_username = templateCommand("username", "Set the template of the name pins are sent under (set to default to restore it)",
+    func(t *database.Templates) *string { return &t.Username }, misc.DEFAULT_USERNAME)
+
+// templateCommand returns an option setting one of the templates of the guild, validating it with a preview
+// Setting a template to "default" restores the default template
+func templateCommand(name string, description string, field func(t *database.Templates) *string, fallback string) Command {
+    return Command{
+        metadata: &discordgo.ApplicationCommandOption{
+            Name: name,
+            Description: description,
+            Type: discordgo.ApplicationCommandOptionString,
+            MaxLength: 500,
+        },
+        handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+            // Fetch config for this guild
+            db := database.Connect()
+            c := db.GetConfig(i.GuildID)
+
+            new_value := strings.TrimSpace(i.ApplicationCommandData().Options[option].StringValue())
+            if new_value == "default" {
+                new_value = ""
+            }
+
+            // Validate template by filling it with example data
+            author, channel := "Alice", "general"
+            if i.Member != nil {
+                author = misc.GetName(i.Member)
+            }
+            if ch, err := discord.State.Channel(i.ChannelID); err == nil {
+                channel = ch.Name
+            }
+            template := new_value
+            if template == "" {
+                template = fallback
+            }
+            preview, err := misc.PreviewTemplate(template, author, channel)
+            if err != nil {
+                respondEmbed(discord, i, &discordgo.MessageEmbed{
+                    Title: ":x:  Invalid " + name + " template",
+                    Description: "```" + err.Error() + "```",
+                })
+                return
+            }
+
+            // Write changes to config and save it
+            if *field(&c.Templates) != new_value {
+                *field(&c.Templates) = new_value
+                err := db.SaveConfig(i.GuildID, c)
+                if err != nil {
+                    log.Printf("Failed to save config: %v", err)
+                    return
+                }
+            }
+
+            // Respond with success
+            respondEmbed(discord, i, &discordgo.MessageEmbed{
+                Title: "Set " + name + " template",
+                Fields: []*discordgo.MessageEmbedField{
+                    { Name: "Template", Value: "```" + truncate(template, MAX_FIELD_LENGTH - 6) + "```" },
+                    { Name: "Preview", Value: truncate(preview, MAX_FIELD_LENGTH) },
+                    {
+                        Name: "Fields",
+                        Value: "`{{.Author}}` `{{.AuthorMention}}` `{{.Channel}}` `{{.ChannelMention}}` `{{.Thread}}` `{{.ThreadMention}}` `{{.Link}}` `{{.Reply}}` `{{.ReplyDeleted}}` `{{.Forwarded}}` `{{.Conversation}}` " +
+                            "`{{.Reactions}}` `{{.Count}}` `{{.Emoji}}` `{{.Date.Format \"2006-01-02\"}}` `{{.Board}}`",
+                    },
+                },
+            })
+        },
+    }
+}
+
+var command_config_forumtags = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "forumtags",
+        Description: "Tag forum posts by the emoji that pinned them, e.g. '⭐ Starred, 🔥 Hot' (no emojis to clear)",
+        Type: discordgo.ApplicationCommandOptionString,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Write changes to config and save it
+        input := i.ApplicationCommandData().Options[option].StringValue()
+        tags := misc.ParseForumTags(input)
+
+        // If no tags are given, clear the forum tags
+        var resp string
+        if len(tags) == 0 {
+            c.ForumTags = make(map[string]string)
+            resp = "Forum tags were cleared"
+        } else {
+            if c.ForumTags == nil {
+                c.ForumTags = make(map[string]string)
+            }
+            for emoji, name := range tags {
+                c.ForumTags[emoji] = name
+            }
+            resp = "Forum tags were updated with the given emojis"
+        }
+
+        err := db.SaveConfig(i.GuildID, c)
+        if err != nil {
+            log.Printf("Failed to save config: %v", err)
+            return
+        }
+
+        var list strings.Builder
+        for _, emoji := range slices.Sorted(maps.Keys(c.ForumTags)) {
+            list.WriteString(fmt.Sprintf("* %s → %s\n", misc.FormatEmoji(discord, i.GuildID, emoji), c.ForumTags[emoji]))
+        }
+
+        // Respond with success
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: []*discordgo.MessageEmbed{
+                    {
+                        Title: resp,
+                        Description: list.String(),
+                    },
+                },
+                Flags:   discordgo.MessageFlagsEphemeral,
+            },
+        })
+    },
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+    "github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/misc"
+)
+
+func registerConversation() error {
+    // Add signature
+    sig := &discordgo.ApplicationCommand{
+        Name: "Pin Conversation",
+        Type: discordgo.MessageApplicationCommand,
+        DefaultMemberPermissions: &permission,
+        DMPermission: &dmPermission,
+    }
+    signatures = append(signatures, sig)
+    handlers[sig.Name] = make(map[string]func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate))
+
+    // Register commands
+    command_conversation.register()
+    index += 1
+
+    return nil
+}
+
+// Command to pin a range of messages as one entry, used once on its first message and once on its last
+var command_conversation = Command{
+    metadata: nil,
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        selected_msg := i.ApplicationCommandData().Resolved.Messages[i.ApplicationCommandData().TargetID]
+        selected_msg.ChannelID = i.ChannelID
+        msg_link := misc.GetMessageLink(i.GuildID, i.ChannelID, selected_msg.ID)
+        user_id := i.Member.User.ID
+
+        // Start the conversation if this is its first message
+        if !misc.HasConversation(i.GuildID, user_id, i.ChannelID) {
+            misc.StartConversation(i.GuildID, user_id, selected_msg)
+            respondEmbed(discord, i, &discordgo.MessageEmbed{
+                Title: ":speech_balloon:  Started a conversation",
+                Description: fmt.Sprintf("Starting at %s. Use **Pin Conversation** on its last message to pin it.", msg_link),
+            })
+            return
+        }
+
+        // Send message acknowledging request
+        embeds := []*discordgo.MessageEmbed{ LoadingEmbed("Pinning conversation...") }
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{ Embeds: embeds },
+        })
+
+        // Queue the pin of every message between the first and this one, behind the other pins of the guild
+        msgs, err := misc.EndConversation(discord, i.GuildID, user_id, selected_msg)
+        if err == nil {
+            var req *misc.PinRequest
+            req, err = misc.CreateConversationRequest(discord, i.GuildID, "", msgs)
+            if err == nil {
+                misc.Queue.Push(req)
+            }
+        }
+
+        // Send a response based on the state of the pin
+        if err != nil {
+            log.Printf("Failed to pin conversation ending at '%s': %v", selected_msg.ID, err)
+            embeds[0].Title = ":x:  Failed to pin conversation"
+            embeds[0].Fields = append(embeds[0].Fields, &discordgo.MessageEmbedField{
+                Name: "Reason",
+                Value: fmt.Sprintf("```%v```", err),
+            })
+
+            // Edit response with state of pin
+            discord.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{ Embeds: &embeds })
+            return
+        }
+
+        first_link := misc.GetMessageLink(i.GuildID, i.ChannelID, msgs[0].ID)
+
+        // Edit response with state of pin
+        resp := fmt.Sprintf("### :pushpin: %s is pinning [a conversation](%s) of %d messages. It will appear on the board shortly.", i.Member.Mention(), first_link, len(msgs))
+        discord.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{ Content: &resp, Embeds: &[]*discordgo.MessageEmbed{} })
+    },
+}
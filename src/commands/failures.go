@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+	"github.com/jadc/redpin/misc"
+)
+
+// Number of failures listed by /redpin-failures view
+const FAILURES_SHOWN = 10
+
+// Maximum length of the error shown for each failure, and of the whole list, as Discord caps embeds at 6000 characters
+const (
+    MAX_FAILURE_ERROR = 200
+    MAX_FAILURES_LENGTH = 5000
+)
+
+// Discord does not allow subcommands next to the options of /redpin, so failures get their own command
+func registerFailures() error {
+    // Add signature
+    sig := &discordgo.ApplicationCommand{
+        Name: "redpin-failures",
+        Description: "View pins that failed to be sent, and retry them",
+        Options: []*discordgo.ApplicationCommandOption{},
+        DefaultMemberPermissions: &permission,
+        DMPermission: &dmPermission,
+    }
+    signatures = append(signatures, sig)
+    handlers[sig.Name] = make(map[string]func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate))
+
+    // Register all subcommands
+    command_failures_view.register()
+    command_failures_retry.register()
+    index += 1
+
+    return nil
+}
+
+var command_failures_view = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "view",
+        Description: "List the most recent pins that failed to be sent",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        db := database.Connect()
+        failures, err := db.GetFailures(i.GuildID, FAILURES_SHOWN)
+        if err != nil {
+            log.Printf("Failed to retrieve failures: %v", err)
+            respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: ":x:  Failed to retrieve failures" })
+            return
+        }
+
+        if len(failures) == 0 {
+            respondEmbed(discord, i, &discordgo.MessageEmbed{
+                Title: "Failures",
+                Description: "No pins have failed",
+            })
+            return
+        }
+
+        embed := &discordgo.MessageEmbed{ Title: "Failures" }
+        length := 0
+        for _, f := range failures {
+            board := ""
+            if f.Board != "" {
+                board = fmt.Sprintf(" (board %s)", f.Board)
+            }
+            field := &discordgo.MessageEmbedField{
+                Name: fmt.Sprintf("#%d", f.ID),
+                Value: fmt.Sprintf("%s%s\n<t:%d:R> after %d attempts\n```%s```",
+                    misc.GetMessageLink(f.GuildID, f.ChannelID, f.MessageID), board,
+                    f.FailedAt.Unix(), f.Attempts, truncate(f.Error, MAX_FAILURE_ERROR)),
+            }
+
+            // Leave out the oldest failures if they do not fit
+            length += len([]rune(field.Name)) + len([]rune(field.Value))
+            if length > MAX_FAILURES_LENGTH {
+                break
+            }
+            embed.Fields = append(embed.Fields, field)
+        }
+        embed.Footer = &discordgo.MessageEmbedFooter{ Text: "Use /redpin-failures retry to queue these again" }
+        respondEmbed(discord, i, embed)
+    },
+}
+
+var command_failures_retry = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "retry",
+        Description: "Queue a failed pin again, or every failed pin if no id is given",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "id",
+                Description: "Number of the failure, as shown by /redpin-failures view",
+                Type: discordgo.ApplicationCommandOptionInteger,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        embeds := []*discordgo.MessageEmbed{ LoadingEmbed("Retrying failed pins...") }
+
+        // Send message acknowledging request, as fetching each message may take a while
+        discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+            Type: discordgo.InteractionResponseChannelMessageWithSource,
+            Data: &discordgo.InteractionResponseData{
+                Embeds: embeds,
+                Flags: discordgo.MessageFlagsEphemeral,
+            },
+        })
+
+        // Retrieve the given failure, or all of them
+        db := database.Connect()
+        var failures []*database.FailedPin
+        if opts := i.ApplicationCommandData().Options[option].Options; len(opts) > 0 {
+            f, err := db.GetFailure(i.GuildID, opts[0].IntValue())
+            if err == nil {
+                failures = append(failures, f)
+            }
+        } else {
+            failures, _ = db.GetFailures(i.GuildID, 0)
+        }
+
+        embeds[0].Title = fmt.Sprintf("Queued %d failed pins", len(failures))
+        var errs strings.Builder
+        for _, f := range failures {
+            if err := misc.Queue.Retry(discord, f); err != nil {
+                log.Printf("Failed to retry failure #%d: %v", f.ID, err)
+                errs.WriteString(fmt.Sprintf("* #%d: %s\n", f.ID, truncate(err.Error(), 100)))
+            }
+        }
+        if errs.Len() > 0 {
+            embeds[0].Title = ":warning:  Some failed pins could not be queued"
+            embeds[0].Description = truncate(errs.String(), 4000)
+        } else if len(failures) == 0 {
+            embeds[0].Title = ":x:  No failed pins to retry"
+        }
+
+        discord.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{ Embeds: &embeds })
+    },
+}
+
+// truncate shortens text to at most n runes, marking it with an ellipsis if shortened
+func truncate(text string, n int) string {
+    runes := []rune(text)
+    if len(runes) <= n {
+        return text
+    }
+    return string(runes[:n-1]) + "…"
+}
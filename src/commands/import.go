@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/misc"
+)
+
+// Imports take options rather than subcommands, so they get their own command
+func registerImport() error {
+    // Add signature
+    sig := &discordgo.ApplicationCommand{
+        Name: "redpin-import",
+        Description: "Pin every message that is natively pinned in a channel or thread",
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "unpin",
+                Description: "Unpin the native pins once they are queued, to stay under the limit of 50 pins",
+                Type: discordgo.ApplicationCommandOptionBoolean,
+            },
+        },
+        DefaultMemberPermissions: &permission,
+        DMPermission: &dmPermission,
+    }
+    signatures = append(signatures, sig)
+    handlers[sig.Name] = make(map[string]func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate))
+
+    // Register command
+    command_import.registerOptions()
+    index += 1
+
+    return nil
+}
+
+var command_import = Command{
+    metadata: nil,
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        unpin := false
+        for _, opt := range i.ApplicationCommandData().Options {
+            if opt.Name == "unpin" {
+                unpin = opt.BoolValue()
+            }
+        }
+
+        // Imports can outlast the interaction, so progress is shown in a regular message
+        respondEmbed(discord, i, &discordgo.MessageEmbed{ Title: "Started import" })
+        embed := LoadingEmbed("Importing pins...")
+        msg, err := discord.ChannelMessageSendEmbed(i.ChannelID, embed)
+        if err != nil {
+            log.Printf("Failed to send import progress message: %v", err)
+        }
+        update := func() {
+            if msg != nil {
+                discord.ChannelMessageEditEmbed(msg.ChannelID, msg.ID, embed)
+            }
+        }
+
+        last := time.Now()
+        p, err := misc.ImportPins(discord, i.GuildID, unpin, func(p *misc.ImportProgress) {
+            if time.Since(last) < BACKFILL_PROGRESS_INTERVAL {
+                return
+            }
+            last = time.Now()
+            embed.Description = fmt.Sprintf("Collected %d pins from %d channels and threads", p.Found, p.Channels)
+            update()
+        })
+        if err != nil {
+            embed.Title = ":x:  Failed to import pins"
+            embed.Description = err.Error()
+            update()
+            return
+        }
+
+        // Respond with results
+        embed.Title = fmt.Sprintf("Imported %d pins", p.Queued)
+        embed.Description = fmt.Sprintf("Found %d pins in %d channels and threads\nSkipped %d that were already pinned",
+            p.Found, p.Channels, p.Skipped)
+        if unpin {
+            embed.Description += fmt.Sprintf("\nUnpinned %d native pins", p.Unpinned)
+        }
+        update()
+    },
+}
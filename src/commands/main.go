@@ -21,7 +21,14 @@ var (
 func RegisterAll(discord *discordgo.Session) error {
     // Populate signature
     registerConfig()
+    registerOverride()
+    registerRoute()
+    registerBoard()
+    registerFailures()
+    registerBackfill()
+    registerImport()
     registerPin()
+    registerConversation()
     registerStats()
 
     // Register signature
@@ -70,3 +77,16 @@ func (cmd *Command) register() {
         handlers[signatures[index].Name][cmd.metadata.Name] = cmd.handler
     }
 }
+
+// registerOptions registers a command handler that reads all of the options of the current command itself
+// The handler runs once per use, rather than once per option given
+func (cmd *Command) registerOptions() {
+    handlers[signatures[index].Name][""] = cmd.handler
+    for _, opt := range signatures[index].Options {
+        handlers[signatures[index].Name][opt.Name] = func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+            if option == 0 {
+                cmd.handler(discord, option, i)
+            }
+        }
+    }
+}
@@ -15,3 +15,14 @@ func LoadingEmbed(task string) *discordgo.MessageEmbed {
         Title: ":hourglass_flowing_sand:  " + t,
     }
 }
+
+// respondEmbed responds to a command with an ephemeral embed
+func respondEmbed(discord *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
+    discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+        Type: discordgo.InteractionResponseChannelMessageWithSource,
+        Data: &discordgo.InteractionResponseData{
+            Embeds: []*discordgo.MessageEmbed{ embed },
+            Flags:   discordgo.MessageFlagsEphemeral,
+        },
+    })
+}
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+	"github.com/jadc/redpin/misc"
+)
+
+// Discord does not allow subcommands next to the options of /redpin, so overrides get their own command
+func registerOverride() error {
+    // Add signature
+    sig := &discordgo.ApplicationCommand{
+        Name: "redpin-override",
+        Description: "Customize the config for a channel or category",
+        Options: []*discordgo.ApplicationCommandOption{},
+        DefaultMemberPermissions: &permission,
+        DMPermission: &dmPermission,
+    }
+    signatures = append(signatures, sig)
+    handlers[sig.Name] = make(map[string]func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate))
+
+    // Register all subcommands
+    command_override_set.register()
+    command_override_view.register()
+    command_override_clear.register()
+    index += 1
+
+    return nil
+}
+
+// Channel types that overrides can be set for
+var override_channel_types = []discordgo.ChannelType{
+    discordgo.ChannelTypeGuildText,
+    discordgo.ChannelTypeGuildNews,
+    discordgo.ChannelTypeGuildForum,
+    discordgo.ChannelTypeGuildCategory,
+    discordgo.ChannelTypeGuildPublicThread,
+    discordgo.ChannelTypeGuildPrivateThread,
+    discordgo.ChannelTypeGuildNewsThread,
+}
+
+var command_override_set_threshold_min = float64(1)
+var command_override_set_replydepth_min = float64(0)
+var command_override_set = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "set",
+        Description: "Override settings for a channel or category (threads inherit from their channel)",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "channel",
+                Description: "Channel or category to override settings for",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: override_channel_types,
+                Required: true,
+            },
+            {
+                Name: "enabled",
+                Description: "Set whether messages here can be pinned at all",
+                Type: discordgo.ApplicationCommandOptionBoolean,
+            },
+            {
+                Name: "threshold",
+                Description: "Set the minimum number of reactions required to pin a message here",
+                Type: discordgo.ApplicationCommandOptionInteger,
+                MinValue: &command_override_set_threshold_min,
+            },
+            {
+                Name: "emoji",
+                Description: "Set which emojis can pin messages here; write 'all' to allow any emoji",
+                Type: discordgo.ApplicationCommandOptionString,
+            },
+            {
+                Name: "selfpin",
+                Description: "Set whether messages here can be pinned by their author",
+                Type: discordgo.ApplicationCommandOptionBoolean,
+            },
+            {
+                Name: "replydepth",
+                Description: "Set the max number of replies pinned when a message here is pinned",
+                Type: discordgo.ApplicationCommandOptionInteger,
+                MinValue: &command_override_set_replydepth_min,
+                MaxValue: 10,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // Find override of the given channel, creating it if it doesn't exist
+        var channel *discordgo.Channel
+        for _, opt := range i.ApplicationCommandData().Options[option].Options {
+            if opt.Name == "channel" {
+                channel = opt.ChannelValue(discord)
+            }
+        }
+        o, ok := c.Overrides[channel.ID]
+        if !ok {
+            o = &database.Override{}
+        }
+
+        // Write each given setting to the override
+        for _, opt := range i.ApplicationCommandData().Options[option].Options {
+            switch opt.Name {
+                case "enabled":
+                    v := opt.BoolValue()
+                    o.Enabled = &v
+                case "threshold":
+                    v := int(opt.IntValue())
+                    o.Threshold = &v
+                case "emoji":
+                    v := misc.ParseEmojiRules(opt.StringValue())
+                    o.Allowlist = &v
+                case "selfpin":
+                    v := opt.BoolValue()
+                    o.Selfpin = &v
+                case "replydepth":
+                    v := int(opt.IntValue())
+                    o.ReplyDepth = &v
+            }
+        }
+
+        // Save changes to config
+        c.Overrides[channel.ID] = o
+        err := db.SaveConfig(i.GuildID, c)
+        if err != nil {
+            log.Printf("Failed to save config: %v", err)
+            return
+        }
+
+        // Respond with success
+        embed, file := formatOverride(o)
+        embed.Title = fmt.Sprintf("Set override for <#%s>", channel.ID)
+        respondOverride(discord, i, embed, file)
+    },
+}
+
+var command_override_view = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "view",
+        Description: "View the overrides of a channel or category, or list every override",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "channel",
+                Description: "Channel or category to view the overrides and resulting config of",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: override_channel_types,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        // If no channel is given, list every channel with an override
+        opts := i.ApplicationCommandData().Options[option].Options
+        if len(opts) == 0 {
+            embed := &discordgo.MessageEmbed{ Title: "Overrides" }
+            if len(c.Overrides) == 0 {
+                embed.Description = "There are no overrides, the config applies to every channel"
+            } else {
+                var list strings.Builder
+                for channel_id := range c.Overrides {
+                    list.WriteString(fmt.Sprintf("* <#%s>\n", channel_id))
+                }
+                embed.Description = truncate(list.String(), MAX_DESCRIPTION_LENGTH)
+            }
+            respondEmbed(discord, i, embed)
+            return
+        }
+
+        channel := opts[0].ChannelValue(discord)
+
+        // Show the override, and the config that results from it and any parent overrides
+        o, ok := c.Overrides[channel.ID]
+        if !ok {
+            o = &database.Override{}
+        }
+        embed, file := formatOverride(o)
+        embed.Title = fmt.Sprintf("Override for <#%s>", channel.ID)
+
+        r, enabled := misc.GetChannelConfig(discord, i.GuildID, "", channel.ID)
+        resolved := fmt.Sprintf(
+            "Enabled: %t\nThreshold: %d\nSelfpin: %t\nReply depth: %d\nEmojis:\n%s",
+            enabled, r.Threshold, r.Selfpin, r.ReplyDepth, formatAllowlist(discord, i.GuildID, r),
+        )
+        embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+            Name: "Resulting Config",
+            Value: truncate(resolved, MAX_FIELD_LENGTH),
+        })
+
+        respondOverride(discord, i, embed, file)
+    },
+}
+
+var command_override_clear = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "clear",
+        Description: "Remove every override of a channel or category",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "channel",
+                Description: "Channel or category to remove the overrides of",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: override_channel_types,
+                Required: true,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        channel := i.ApplicationCommandData().Options[option].Options[0].ChannelValue(discord)
+
+        // Write changes to config and save it
+        if _, ok := c.Overrides[channel.ID]; ok {
+            delete(c.Overrides, channel.ID)
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        respondEmbed(discord, i, &discordgo.MessageEmbed{
+            Title: fmt.Sprintf("Cleared override for <#%s>", channel.ID),
+        })
+    },
+}
+
+// formatOverride returns an embed about an override, along with its settings as a file, as they outgrow the limits of an embed
+func formatOverride(o *database.Override) (*discordgo.MessageEmbed, *discordgo.File) {
+    j, err := json.MarshalIndent(o, "", "    ")
+    if err != nil {
+        log.Printf("Failed to marshal override: %v", err)
+    }
+
+    embed := &discordgo.MessageEmbed{ Description: "The overridden settings are attached" }
+    file := &discordgo.File{
+        Name: "override.json",
+        ContentType: "application/json",
+        Reader: strings.NewReader(string(j)),
+    }
+    return embed, file
+}
+
+// respondOverride responds with an embed about an override, attaching its settings
+func respondOverride(discord *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed, file *discordgo.File) {
+    err := discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+        Type: discordgo.InteractionResponseChannelMessageWithSource,
+        Data: &discordgo.InteractionResponseData{
+            Embeds: []*discordgo.MessageEmbed{ embed },
+            Files: []*discordgo.File{ file },
+            Flags:   discordgo.MessageFlagsEphemeral,
+        },
+    })
+    if err != nil {
+        log.Printf("Failed to respond with override: %v", err)
+    }
+}
@@ -41,7 +41,7 @@ var command_pin = Command{
         })
 
         // Pin the selected message (skipping queue)
-        req, err := misc.CreatePinRequest(discord, i.GuildID, selected_msg)
+        req, err := misc.CreatePinRequest(discord, i.GuildID, "", selected_msg)
         if err != nil {
             log.Printf("Failed to create pin request for message '%s': %v", selected_msg.ID, err)
             return
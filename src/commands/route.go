@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Discord does not allow subcommands next to the options of /redpin, so routes get their own command
+func registerRoute() error {
+    // Add signature
+    sig := &discordgo.ApplicationCommand{
+        Name: "redpin-route",
+        Description: "Send pins from a channel or category to a different pin channel",
+        Options: []*discordgo.ApplicationCommandOption{},
+        DefaultMemberPermissions: &permission,
+        DMPermission: &dmPermission,
+    }
+    signatures = append(signatures, sig)
+    handlers[sig.Name] = make(map[string]func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate))
+
+    // Register all subcommands
+    command_route_set.register()
+    command_route_view.register()
+    command_route_clear.register()
+    index += 1
+
+    return nil
+}
+
+var command_route_set = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "set",
+        Description: "Send pins of messages in a channel or category to the given pin channel",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "source",
+                Description: "Channel or category whose pins are routed",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: override_channel_types,
+                Required: true,
+            },
+            {
+                Name: "destination",
+                Description: "Channel to send the pins to",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: []discordgo.ChannelType{
+                    discordgo.ChannelTypeGuildText,
+                    discordgo.ChannelTypeGuildForum,
+                },
+                Required: true,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        var source, destination string
+        for _, opt := range i.ApplicationCommandData().Options[option].Options {
+            switch opt.Name {
+                case "source":
+                    source = opt.ChannelValue(discord).ID
+                case "destination":
+                    destination = opt.ChannelValue(discord).ID
+            }
+        }
+
+        // Write changes to config and save it
+        if c.Routes[source] != destination {
+            c.Routes[source] = destination
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        respondEmbed(discord, i, &discordgo.MessageEmbed{
+            Title: "Set route",
+            Description: fmt.Sprintf("Pins from <#%s> are now sent to <#%s>", source, destination),
+        })
+    },
+}
+
+var command_route_view = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "view",
+        Description: "List every route",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        embed := &discordgo.MessageEmbed{ Title: "Routes" }
+        var list strings.Builder
+        for source, destination := range c.Routes {
+            list.WriteString(fmt.Sprintf("* <#%s> → <#%s>\n", source, destination))
+        }
+        list.WriteString(fmt.Sprintf("* Everything else → <#%s>\n", c.Channel))
+        embed.Description = list.String()
+
+        respondEmbed(discord, i, embed)
+    },
+}
+
+var command_route_clear = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "clear",
+        Description: "Send pins from a channel or category to the default pin channel again",
+        Type: discordgo.ApplicationCommandOptionSubCommand,
+        Options: []*discordgo.ApplicationCommandOption{
+            {
+                Name: "source",
+                Description: "Channel or category to remove the route of",
+                Type: discordgo.ApplicationCommandOptionChannel,
+                ChannelTypes: override_channel_types,
+                Required: true,
+            },
+        },
+    },
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // Fetch config for this guild
+        db := database.Connect()
+        c := db.GetConfig(i.GuildID)
+
+        source := i.ApplicationCommandData().Options[option].Options[0].ChannelValue(discord).ID
+
+        // Write changes to config and save it
+        if _, ok := c.Routes[source]; ok {
+            delete(c.Routes, source)
+            err := db.SaveConfig(i.GuildID, c)
+            if err != nil {
+                log.Printf("Failed to save config: %v", err)
+                return
+            }
+        }
+
+        // Respond with success
+        respondEmbed(discord, i, &discordgo.MessageEmbed{
+            Title: "Cleared route",
+            Description: fmt.Sprintf("Pins from <#%s> are now sent to <#%s>", source, c.Channel),
+        })
+    },
+}
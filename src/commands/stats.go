@@ -29,6 +29,7 @@ func registerStats() error {
     // Register commands
     command_stats_leaderboard.register()
     command_stats_user.register()
+    command_stats_board.register()
     index += 1
 
     return nil
@@ -47,7 +48,7 @@ var command_stats_leaderboard = Command{
         // Connect to database
         db := database.Connect()
 
-        lb, err := db.GetLeaderboard(i.GuildID)
+        lb, err := db.GetLeaderboard(i.GuildID, statsBoard(i))
         if err != nil {
             log.Printf("Failed to retrieve leaderboard: %v", err)
             return
@@ -111,7 +112,7 @@ var command_stats_user = Command{
             Data: &discordgo.InteractionResponseData{ Embeds: embeds },
         })
 
-        if user := i.ApplicationCommandData().Options[0].UserValue(discord); user != nil {
+        if user := i.ApplicationCommandData().Options[option].UserValue(discord); user != nil {
             // Connect to database
             db := database.Connect()
 
@@ -124,7 +125,7 @@ var command_stats_user = Command{
             }
 
             // Build embed contents
-            stats, err := db.GetStats(i.GuildID, user.ID)
+            stats, err := db.GetStats(i.GuildID, statsBoard(i), user.ID)
             if err != nil {
                 log.Printf("Failed to retrieve user stats: %v", err)
                 return
@@ -147,3 +148,30 @@ var command_stats_user = Command{
     },
 }
 
+var command_stats_board = Command{
+    metadata: &discordgo.ApplicationCommandOption{
+        Name: "board",
+        Description: "Set to view statistics of a board other than the default one",
+        Type: discordgo.ApplicationCommandOptionString,
+    },
+
+    handler: func(discord *discordgo.Session, option int, i *discordgo.InteractionCreate) {
+        // If a user is also given, their breakdown is shown by the user option instead
+        for _, opt := range i.ApplicationCommandData().Options {
+            if opt.Name == "user" {
+                return
+            }
+        }
+        command_stats_leaderboard.handler(discord, option, i)
+    },
+}
+
+// statsBoard returns the name of the board given to a stats command, or "" for the default board
+func statsBoard(i *discordgo.InteractionCreate) string {
+    for _, opt := range i.ApplicationCommandData().Options {
+        if opt.Name == "board" {
+            return opt.StringValue()
+        }
+    }
+    return ""
+}
@@ -4,16 +4,128 @@ import (
 	"context"
 	"fmt"
     "log"
+    "maps"
+    "slices"
     "encoding/json"
 )
 
+// Ways of counting reactions towards the threshold
+const (
+    // Any single emoji must reach the threshold
+    COUNT_EACH = "each"
+
+    // Unique members reacting with any allowed emoji must reach the threshold
+    COUNT_SUM = "sum"
+)
+
+// Ways of handling messages pinned natively by members
+const (
+    // Native pins are left alone
+    NATIVE_IGNORE = "ignore"
+
+    // Native pins are copied to the default board
+    NATIVE_MIRROR = "mirror"
+
+    // Native pins are copied to the default board, then unpinned to free the slot
+    NATIVE_UNPIN = "unpin"
+)
+
+// Ways of sending pins to the pin channel
+const (
+    // Copies of the message sent through webhooks, impersonating the author
+    RENDER_WEBHOOK = "webhook"
+
+    // An embed of the message sent by the bot itself
+    RENDER_EMBED = "embed"
+)
+
+// Ways of laying out pins sent through webhooks
+const (
+    // Separate messages for the reply context, the copy of the message, extra attachments and the footer
+    LAYOUT_CLASSIC = "classic"
+
+    // One message holding everything, with a button linking to the message
+    LAYOUT_COMPACT = "compact"
+)
+
 type Config struct {
     Channel     string              `json:"channel"`
+
+    // Thread of the pin channel that pins are sent into instead of the channel itself ("" to send to the channel)
+    Thread      string              `json:"thread"`
+
     Threshold   int                 `json:"threshold"`
     NSFW        bool                `json:"nsfw"`
     Selfpin     bool                `json:"selfpin"`
     ReplyDepth  int                 `json:"replyDepth"`
-    Allowlist   map[string]struct{} `json:"allowlist"`
+    Allowlist   Allowlist           `json:"allowlist"`
+
+    // Number of preceding messages of the same channel shown as context of pins (0 to disable),
+    // and how many minutes before the pinned message they may be from (0 for no limit)
+    ContextDepth int                `json:"contextDepth"`
+    ContextWindow int               `json:"contextWindow"`
+
+    // Minutes after pinning during which a pin is retracted if it falls below the threshold (0 to disable)
+    RetractWindow int               `json:"retractWindow"`
+
+    // Whether edits to pinned messages are mirrored to their pins
+    Mirror      bool                `json:"mirror"`
+
+    // How reactions are counted towards the threshold (each or sum)
+    CountMode   string              `json:"countMode"`
+
+    // Map of channel or category id -> settings that replace the guild-wide ones there
+    Overrides   map[string]*Override `json:"overrides"`
+
+    // Map of source channel or category id -> pin channel id that its pins are sent to instead
+    Routes      map[string]string   `json:"routes"`
+
+    // Map of name -> additional board that messages can be pinned to, alongside the default board
+    Boards      map[string]*Board   `json:"boards"`
+
+    // Hours before going offline to scan for messages that qualified while offline (0 to disable)
+    CatchupWindow int               `json:"catchupWindow"`
+
+    // How native pins are handled (ignore, mirror or unpin)
+    NativePins  string              `json:"nativePins"`
+
+    // Whether natively unpinning a message removes its copy from the default board
+    NativeUnpin bool                `json:"nativeUnpin"`
+
+    // How pins are sent to the pin channel (webhook or embed)
+    Render      string              `json:"render"`
+
+    // How pins sent through webhooks are laid out (classic or compact)
+    Layout      string              `json:"layout"`
+
+    // Templates of the text around pins, empty to use the defaults
+    Templates   Templates           `json:"templates"`
+
+    // Map of emoji -> name of the forum tag applied to posts pinned with it, when the pin channel is a forum
+    ForumTags   map[string]string   `json:"forumTags"`
+}
+
+// Templates customize the text around pins with text/template; empty templates use the default
+type Templates struct {
+    Header      string              `json:"header,omitempty"`
+    Footer      string              `json:"footer,omitempty"`
+    Username    string              `json:"username,omitempty"`
+}
+
+// Board is an independent pin channel, with its own emojis, threshold and statistics
+type Board struct {
+    Channel     string              `json:"channel"`
+    Threshold   int                 `json:"threshold"`
+    Allowlist   Allowlist           `json:"allowlist"`
+}
+
+// Override replaces guild-wide settings for a channel or category; unset (nil) settings are inherited
+type Override struct {
+    Enabled     *bool               `json:"enabled,omitempty"`
+    Threshold   *int                `json:"threshold,omitempty"`
+    Allowlist   *Allowlist          `json:"allowlist,omitempty"`
+    Selfpin     *bool               `json:"selfpin,omitempty"`
+    ReplyDepth  *int                `json:"replyDepth,omitempty"`
 }
 
 func (c *Config) New() *Config {
@@ -22,10 +134,171 @@ func (c *Config) New() *Config {
     c.NSFW = false
     c.Selfpin = false
     c.ReplyDepth = 1
-    c.Allowlist = make(map[string]struct{})
+    c.ContextDepth = 0
+    c.ContextWindow = 0
+    c.Allowlist = make(Allowlist)
+    c.RetractWindow = 0
+    c.Mirror = false
+    c.CountMode = COUNT_EACH
+    c.Overrides = make(map[string]*Override)
+    c.Routes = make(map[string]string)
+    c.Boards = make(map[string]*Board)
+    c.CatchupWindow = 24
+    c.NativePins = NATIVE_MIRROR
+    c.NativeUnpin = false
+    c.Render = RENDER_WEBHOOK
+    c.Layout = LAYOUT_CLASSIC
+    c.ForumTags = make(map[string]string)
     return c
 }
 
+// EmojiRule customizes how reactions with an allowed emoji count towards pinning a message
+type EmojiRule struct {
+    // Points each reaction is worth (0 counts as 1)
+    Weight      int                 `json:"weight,omitempty"`
+
+    // Points needed to pin a message with this emoji alone (0 uses the guild threshold)
+    Threshold   int                 `json:"threshold,omitempty"`
+}
+
+// Allowlist maps each emoji that can pin messages to its rule; if empty, any emoji can pin
+type Allowlist map[string]EmojiRule
+
+// UnmarshalJSON loads an allowlist, including ones stored as a plain set or list of emojis by older versions
+func (a *Allowlist) UnmarshalJSON(data []byte) error {
+    rules := make(map[string]EmojiRule)
+    if err := json.Unmarshal(data, &rules); err == nil {
+        *a = rules
+        return nil
+    }
+
+    var emojis []string
+    if err := json.Unmarshal(data, &emojis); err != nil {
+        return fmt.Errorf("Allowlist is neither a map nor a list of emojis: %w", err)
+    }
+
+    *a = make(Allowlist)
+    for _, emoji := range emojis {
+        (*a)[emoji] = EmojiRule{}
+    }
+    return nil
+}
+
+// Allows returns whether reactions with the given emoji can pin messages
+func (a Allowlist) Allows(emoji string) bool {
+    if len(a) == 0 {
+        return true
+    }
+    _, ok := a[emoji]
+    return ok
+}
+
+// Weight returns the points each reaction with the given emoji is worth
+func (a Allowlist) Weight(emoji string) int {
+    if rule, ok := a[emoji]; ok && rule.Weight > 0 {
+        return rule.Weight
+    }
+    return 1
+}
+
+// Threshold returns the points needed to pin a message with the given emoji alone
+func (a Allowlist) Threshold(emoji string, fallback int) int {
+    if rule, ok := a[emoji]; ok && rule.Threshold > 0 {
+        return rule.Threshold
+    }
+    return fallback
+}
+
+// IsPinChannel returns whether pins are sent to the given channel, by default, by a route or by a board
+func (c *Config) IsPinChannel(channel_id string) bool {
+    if channel_id == c.Channel {
+        return true
+    }
+    for _, dest := range c.Routes {
+        if channel_id == dest {
+            return true
+        }
+    }
+    for _, b := range c.Boards {
+        if channel_id == b.Channel {
+            return true
+        }
+    }
+    return false
+}
+
+// BoardNames returns the name of every board, starting with the default board ("")
+func (c *Config) BoardNames() []string {
+    return append([]string{ "" }, slices.Sorted(maps.Keys(c.Boards))...)
+}
+
+// Board returns a copy of the config for pinning to the named board, and whether the board exists
+// The default board is named "". Other boards replace the channel, emojis and threshold,
+// and ignore routes and any overrides of those settings.
+func (c *Config) Board(name string) (*Config, bool) {
+    if name == "" {
+        return c, true
+    }
+
+    b, ok := c.Boards[name]
+    if !ok {
+        return c, false
+    }
+
+    r := *c
+    r.Channel = b.Channel
+    r.Thread = ""
+    r.Threshold = b.Threshold
+    r.Allowlist = b.Allowlist
+    r.Routes = nil
+    r.Boards = nil
+
+    r.Overrides = make(map[string]*Override)
+    for channel_id, o := range c.Overrides {
+        r.Overrides[channel_id] = &Override{ Enabled: o.Enabled, Selfpin: o.Selfpin, ReplyDepth: o.ReplyDepth }
+    }
+
+    return &r, true
+}
+
+// Resolve returns a copy of the config with the overrides and routes of the given channels applied,
+// and whether pinning is enabled in them. Channels are ordered from most to least specific
+// (e.g. thread, channel, category), so overrides of earlier channels take precedence.
+func (c *Config) Resolve(channel_ids ...string) (*Config, bool) {
+    r := *c
+    enabled := true
+
+    for _, channel_id := range slices.Backward(channel_ids) {
+        if dest, ok := c.Routes[channel_id]; ok {
+            r.Channel = dest
+            r.Thread = ""
+        }
+
+        o, ok := c.Overrides[channel_id]
+        if !ok {
+            continue
+        }
+
+        if o.Enabled != nil {
+            enabled = *o.Enabled
+        }
+        if o.Threshold != nil {
+            r.Threshold = *o.Threshold
+        }
+        if o.Allowlist != nil {
+            r.Allowlist = *o.Allowlist
+        }
+        if o.Selfpin != nil {
+            r.Selfpin = *o.Selfpin
+        }
+        if o.ReplyDepth != nil {
+            r.ReplyDepth = *o.ReplyDepth
+        }
+    }
+
+    return &r, enabled
+}
+
 // createConfigTable creates a guild_id -> json data table.
 func (db *database) createConfigTable() error {
     query := fmt.Sprintf(`
@@ -46,7 +319,10 @@ func (db *database) createConfigTable() error {
 // GetConfig retrieves the config for a given guild_id, loads if it isn't.
 func (db *database) GetConfig(guild_id string) *Config {
     // Return config in memory if it is
-    if c, ok := db.Config[guild_id]; ok {
+    db.configMu.RLock()
+    c, ok := db.Config[guild_id]
+    db.configMu.RUnlock()
+    if ok {
         return c
     }
 
@@ -57,6 +333,13 @@ func (db *database) GetConfig(guild_id string) *Config {
         log.Printf("Using default config for guild '%s': %v\n", guild_id, err)
         db.SaveConfig(guild_id, c)
     }
+
+    // Keep the config another caller loaded or saved in the meantime, so every caller shares one
+    db.configMu.Lock()
+    defer db.configMu.Unlock()
+    if loaded, ok := db.Config[guild_id]; ok {
+        return loaded
+    }
     db.Config[guild_id] = c
     return c
 }
@@ -115,7 +398,9 @@ func (db *database) SaveConfig(guild_id string, c *Config) error {
     }
 
     // Update config in memory
+    db.configMu.Lock()
     db.Config[guild_id] = c
+    db.configMu.Unlock()
 
     return nil
 }
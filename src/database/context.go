@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContextPin identifies a pin that shows a given message as context
+type ContextPin struct {
+    Board string
+    ChannelID string
+    MessageID string
+}
+
+// createPinContextTable creates a table of the messages shown as context of each pin for a given guild_id.
+func (db *database) createPinContextTable(guild_id string) error {
+    query := fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS pin_context_%s (
+            message_id TEXT NOT NULL,
+            board TEXT NOT NULL DEFAULT '',
+            channel_id TEXT NOT NULL,
+            context_id TEXT NOT NULL,
+            PRIMARY KEY (message_id, board, context_id)
+        )
+    `, guild_id)
+    _, err := db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to create pin_context_%s table: %w", guild_id, err)
+    }
+    return nil
+}
+
+// AddPinContext records the messages of channel_id shown as context of the pin of message_id on a board, oldest first.
+func (db *database) AddPinContext(guild_id string, board string, channel_id string, message_id string, context_ids []string) error {
+    // Create guild pin context table if it doesn't exist
+    err := db.createPinContextTable(guild_id)
+    if err != nil {
+        return err
+    }
+
+    query := fmt.Sprintf(`INSERT OR IGNORE INTO pin_context_%s (message_id, board, channel_id, context_id) VALUES (?, ?, ?, ?)`, guild_id)
+    for _, context_id := range context_ids {
+        _, err = db.Instance.ExecContext(context.Background(), query, message_id, board, channel_id, context_id)
+        if err != nil {
+            return fmt.Errorf("Failed to insert into table: %w", err)
+        }
+    }
+    return nil
+}
+
+// GetPinContext retrieves the ids of the messages shown as context of the pin of message_id on a board, oldest first.
+func (db *database) GetPinContext(guild_id string, board string, message_id string) ([]string, error) {
+    // Create guild pin context table if it doesn't exist
+    err := db.createPinContextTable(guild_id)
+    if err != nil {
+        return nil, err
+    }
+
+    query := fmt.Sprintf(`SELECT context_id FROM pin_context_%s WHERE message_id = ? AND board = ? ORDER BY rowid`, guild_id)
+    rows, err := db.Instance.QueryContext(context.Background(), query, message_id, board)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var ids []string
+    for rows.Next() {
+        var id string
+        if err := rows.Scan(&id); err != nil {
+            return nil, err
+        }
+        ids = append(ids, id)
+    }
+
+    return ids, rows.Err()
+}
+
+// GetContextPins retrieves every pin that shows context_id as context.
+func (db *database) GetContextPins(guild_id string, context_id string) ([]*ContextPin, error) {
+    // Create guild pin context table if it doesn't exist
+    err := db.createPinContextTable(guild_id)
+    if err != nil {
+        return nil, err
+    }
+
+    query := fmt.Sprintf(`SELECT board, channel_id, message_id FROM pin_context_%s WHERE context_id = ?`, guild_id)
+    rows, err := db.Instance.QueryContext(context.Background(), query, context_id)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var pins []*ContextPin
+    for rows.Next() {
+        var p ContextPin
+        if err := rows.Scan(&p.Board, &p.ChannelID, &p.MessageID); err != nil {
+            return nil, err
+        }
+        pins = append(pins, &p)
+    }
+
+    return pins, rows.Err()
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type FailedPin struct {
+    ID int64
+    GuildID string
+    Board string
+    ChannelID string
+    MessageID string
+
+    // Last message of the conversation this request pins, "" if it pins a single message
+    LastID string
+
+    Attempts int
+    Error string
+    FailedAt time.Time
+}
+
+// createFailureTable creates a table of pin requests that were given up on.
+func (db *database) createFailureTable() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS failures (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            guild_id TEXT NOT NULL,
+            board TEXT NOT NULL,
+            channel_id TEXT NOT NULL,
+            message_id TEXT NOT NULL,
+            last_id TEXT NOT NULL DEFAULT '',
+            attempts INTEGER NOT NULL,
+            error TEXT NOT NULL,
+            failed_at INTEGER NOT NULL
+        )
+    `
+    _, err := db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to create failures table: %w", err)
+    }
+
+    // Tables created before conversations were queued lack this column
+    return db.addColumn("failures", "last_id", "TEXT NOT NULL DEFAULT ''")
+}
+
+// AddFailure inserts a pin request that was given up on into the failures table, along with the error it last failed with.
+// last_id is the last message of the conversation the request pins, or "" if it pins a single message.
+func (db *database) AddFailure(guild_id string, board string, channel_id string, message_id string, last_id string, attempts int, reason string) error {
+    // Create failures table if it doesn't exist
+    err := db.createFailureTable()
+    if err != nil {
+        return err
+    }
+
+    _, err = db.Instance.ExecContext(context.Background(),
+        `INSERT INTO failures (guild_id, board, channel_id, message_id, last_id, attempts, error, failed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+        guild_id, board, channel_id, message_id, last_id, attempts, reason, time.Now().Unix())
+    if err != nil {
+        return fmt.Errorf("Failed to insert into table: %w", err)
+    }
+    return nil
+}
+
+// GetFailures retrieves the most recent failed pin requests of a guild, newest first. A limit of 0 retrieves all of them.
+func (db *database) GetFailures(guild_id string, limit int) ([]*FailedPin, error) {
+    // Create failures table if it doesn't exist
+    err := db.createFailureTable()
+    if err != nil {
+        return nil, err
+    }
+
+    if limit <= 0 {
+        limit = -1
+    }
+    rows, err := db.Instance.QueryContext(context.Background(), `
+        SELECT id, guild_id, board, channel_id, message_id, last_id, attempts, error, failed_at
+        FROM failures
+        WHERE guild_id = ?
+        ORDER BY id DESC
+        LIMIT ?`, guild_id, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var failures []*FailedPin
+    for rows.Next() {
+        var f FailedPin
+        var failed_at int64
+        if err := rows.Scan(&f.ID, &f.GuildID, &f.Board, &f.ChannelID, &f.MessageID, &f.LastID, &f.Attempts, &f.Error, &failed_at); err != nil {
+            return nil, err
+        }
+        f.FailedAt = time.Unix(failed_at, 0)
+        failures = append(failures, &f)
+    }
+
+    return failures, rows.Err()
+}
+
+// GetFailure retrieves a single failed pin request of a guild by its id.
+func (db *database) GetFailure(guild_id string, id int64) (*FailedPin, error) {
+    // Create failures table if it doesn't exist
+    err := db.createFailureTable()
+    if err != nil {
+        return nil, err
+    }
+
+    var f FailedPin
+    var failed_at int64
+    err = db.Instance.QueryRowContext(context.Background(), `
+        SELECT id, guild_id, board, channel_id, message_id, last_id, attempts, error, failed_at
+        FROM failures
+        WHERE guild_id = ? AND id = ?`, guild_id, id,
+    ).Scan(&f.ID, &f.GuildID, &f.Board, &f.ChannelID, &f.MessageID, &f.LastID, &f.Attempts, &f.Error, &failed_at)
+    if err != nil {
+        return nil, err
+    }
+    f.FailedAt = time.Unix(failed_at, 0)
+
+    return &f, nil
+}
+
+// RemoveFailure deletes a failed pin request from the failures table.
+func (db *database) RemoveFailure(id int64) error {
+    // Create failures table if it doesn't exist
+    err := db.createFailureTable()
+    if err != nil {
+        return err
+    }
+
+    _, err = db.Instance.ExecContext(context.Background(), `DELETE FROM failures WHERE id = ?`, id)
+    if err != nil {
+        return fmt.Errorf("Failed to delete from table: %w", err)
+    }
+    return nil
+}
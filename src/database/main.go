@@ -1,7 +1,9 @@
 package database
 
 import (
+    "context"
     "database/sql"
+	"fmt"
 	"log"
 	"os"
     "sync"
@@ -12,7 +14,11 @@ import (
 // Enclose sqlite3 instance in a struct to have methods attached to it
 type database struct {
     Instance *sql.DB
+
+    // Map of guild id -> config loaded in memory
+    // Read and written by event handlers, pin workers and scans at once, so guarded by configMu
     Config map[string]*Config
+    configMu sync.RWMutex
 }
 var db *database
 
@@ -42,8 +48,33 @@ func Connect() *database {
             log.Fatal("Failed to ping database: ", err)
         }
 
-        db = &database{instance, make(map[string]*Config)}
+        db = &database{ Instance: instance, Config: make(map[string]*Config) }
     })
 
     return db
 }
+
+// addColumn adds a column to an existing table, if the table does not have it yet.
+// Used to migrate tables created by older versions.
+func (db *database) addColumn(table string, column string, definition string) error {
+    var count int
+    err := db.Instance.QueryRowContext(
+        context.Background(),
+        "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column,
+    ).Scan(&count)
+    if err != nil {
+        return fmt.Errorf("Failed to inspect table %s: %w", table, err)
+    }
+
+    // Column already exists
+    if count > 0 {
+        return nil
+    }
+
+    query := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)
+    _, err = db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to add column %s to table %s: %w", column, table, err)
+    }
+    return nil
+}
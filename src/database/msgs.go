@@ -12,6 +12,8 @@ func (db *database) createPinTable(guild_id string) error {
             message_id TEXT NOT NULL,
             pin_channel_id TEXT NOT NULL,
             pin_id TEXT NOT NULL,
+            board TEXT NOT NULL DEFAULT '',
+            thread_id TEXT NOT NULL DEFAULT '',
             PRIMARY KEY (message_id, pin_channel_id, pin_id)
         )
     `, guild_id)
@@ -19,11 +21,17 @@ func (db *database) createPinTable(guild_id string) error {
     if err != nil {
         return fmt.Errorf("Failed to create pins_%s table: %w", guild_id, err)
     }
-    return nil
+
+    // Tables created before boards existed, or before forum posts were recorded, lack these columns
+    if err := db.addColumn("pins_" + guild_id, "board", "TEXT NOT NULL DEFAULT ''"); err != nil {
+        return err
+    }
+    return db.addColumn("pins_" + guild_id, "thread_id", "TEXT NOT NULL DEFAULT ''")
 }
 
-// AddPin inserts a message_id -> pin_id pair into the guild_id table.
-func (db *database) AddPin(guild_id string, pin_channel_id string, message_id string, pin_id string) error {
+// AddPin inserts a message_id -> pin_id pair for a board into the guild_id table,
+// along with the forum post created for the pin (if any).
+func (db *database) AddPin(guild_id string, board string, pin_channel_id string, message_id string, pin_id string, thread_id string) error {
     // Create guild pins table if it doesn't exist
     err := db.createPinTable(guild_id)
     if err != nil {
@@ -31,16 +39,16 @@ func (db *database) AddPin(guild_id string, pin_channel_id string, message_id st
     }
 
     // Insert message
-    query := fmt.Sprintf(`INSERT INTO pins_%s (message_id, pin_channel_id, pin_id) VALUES (?, ?, ?)`, guild_id)
-    _, err = db.Instance.ExecContext(context.Background(), query, message_id, pin_channel_id, pin_id)
+    query := fmt.Sprintf(`INSERT INTO pins_%s (message_id, pin_channel_id, pin_id, board, thread_id) VALUES (?, ?, ?, ?, ?)`, guild_id)
+    _, err = db.Instance.ExecContext(context.Background(), query, message_id, pin_channel_id, pin_id, board, thread_id)
     if err != nil {
         return fmt.Errorf("Failed to insert into table: %w", err)
     }
     return nil
 }
 
-// GetPin retrieves the pin message id from the guild_id table given a guild_id and message_id.
-func (db *database) GetPin(guild_id string, message_id string) (string, string, error) {
+// GetPin retrieves the pin message id on a board from the guild_id table given a guild_id and message_id.
+func (db *database) GetPin(guild_id string, board string, message_id string) (string, string, error) {
     // Create guild pins table if it doesn't exist
     err := db.createPinTable(guild_id)
     if err != nil {
@@ -52,7 +60,7 @@ func (db *database) GetPin(guild_id string, message_id string) (string, string,
     pin_channel_id := ""
     err = db.Instance.QueryRowContext(
         context.Background(),
-        "SELECT pin_channel_id, pin_id FROM pins_" + guild_id + " WHERE message_id = ?", message_id,
+        "SELECT pin_channel_id, pin_id FROM pins_" + guild_id + " WHERE message_id = ? AND board = ?", message_id, board,
     ).Scan(&pin_channel_id, &pin_id)
 
     // Throw up any error
@@ -62,3 +70,182 @@ func (db *database) GetPin(guild_id string, message_id string) (string, string,
 
     return pin_channel_id, pin_id, nil
 }
+
+// GetPinThread retrieves the id of the forum post created for the pin of message_id on a board, or "" if there is none.
+func (db *database) GetPinThread(guild_id string, board string, message_id string) (string, error) {
+    // Create guild pins table if it doesn't exist
+    err := db.createPinTable(guild_id)
+    if err != nil {
+        return "", err
+    }
+
+    thread_id := ""
+    err = db.Instance.QueryRowContext(
+        context.Background(),
+        "SELECT thread_id FROM pins_" + guild_id + " WHERE message_id = ? AND board = ?", message_id, board,
+    ).Scan(&thread_id)
+    if err != nil {
+        return "", err
+    }
+
+    return thread_id, nil
+}
+
+// GetPinBoards retrieves the name of every board a message is pinned on.
+func (db *database) GetPinBoards(guild_id string, message_id string) ([]string, error) {
+    // Create guild pins table if it doesn't exist
+    err := db.createPinTable(guild_id)
+    if err != nil {
+        return nil, err
+    }
+
+    query := fmt.Sprintf(`SELECT board FROM pins_%s WHERE message_id = ?`, guild_id)
+    rows, err := db.Instance.QueryContext(context.Background(), query, message_id)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var boards []string
+    for rows.Next() {
+        var board string
+        if err := rows.Scan(&board); err != nil {
+            return nil, err
+        }
+        boards = append(boards, board)
+    }
+
+    return boards, rows.Err()
+}
+
+// Kinds of messages sent to the pin channel for a single pin
+const (
+    PART_HEADER = "header"
+    PART_BODY = "body"
+    PART_ATTACHMENT = "attachment"
+    PART_FOOTER = "footer"
+
+    // Quote of the messages sent before the pinned message, sent before the header
+    PART_CONTEXT = "context"
+
+    // Copy of a message of a conversation other than its first, which stands for the pin
+    PART_CONVERSATION = "conversation"
+
+    // Message sent by the bot itself holding both the copy of the message (as an embed) and the footer
+    PART_EMBED = "embed"
+
+    // Webhook message holding the reply context, copy of the message, attachments and footer together
+    PART_COMPACT = "compact"
+)
+
+type PinMessage struct {
+    ChannelID string
+    MessageID string
+    Kind string
+    WebhookID string
+}
+
+// createPinMessageTable creates a table of every message sent for each pin for a given guild_id.
+func (db *database) createPinMessageTable(guild_id string) error {
+    query := fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS pin_messages_%s (
+            message_id TEXT NOT NULL,
+            pin_channel_id TEXT NOT NULL,
+            pin_msg_id TEXT NOT NULL,
+            kind TEXT NOT NULL,
+            webhook_id TEXT NOT NULL DEFAULT '',
+            board TEXT NOT NULL DEFAULT '',
+            PRIMARY KEY (message_id, pin_msg_id)
+        )
+    `, guild_id)
+    _, err := db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to create pin_messages_%s table: %w", guild_id, err)
+    }
+
+    // Tables created before webhooks were recorded, or before boards existed, lack these columns
+    if err := db.addColumn("pin_messages_" + guild_id, "webhook_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+        return err
+    }
+    return db.addColumn("pin_messages_" + guild_id, "board", "TEXT NOT NULL DEFAULT ''")
+}
+
+// AddPinMessage records a message that was sent to the pin channel, by the given webhook, as part of the pin for message_id on a board.
+func (db *database) AddPinMessage(guild_id string, board string, message_id string, m *PinMessage) error {
+    // Create guild pin messages table if it doesn't exist
+    err := db.createPinMessageTable(guild_id)
+    if err != nil {
+        return err
+    }
+
+    query := fmt.Sprintf(`INSERT INTO pin_messages_%s (message_id, pin_channel_id, pin_msg_id, kind, webhook_id, board) VALUES (?, ?, ?, ?, ?, ?)`, guild_id)
+    _, err = db.Instance.ExecContext(context.Background(), query, message_id, m.ChannelID, m.MessageID, m.Kind, m.WebhookID, board)
+    if err != nil {
+        return fmt.Errorf("Failed to insert into table: %w", err)
+    }
+    return nil
+}
+
+// GetPinMessages retrieves every message that was sent to the pin channel for message_id on a board, in the order they were sent.
+func (db *database) GetPinMessages(guild_id string, board string, message_id string) ([]*PinMessage, error) {
+    // Create guild pin messages table if it doesn't exist
+    err := db.createPinMessageTable(guild_id)
+    if err != nil {
+        return nil, err
+    }
+
+    query := fmt.Sprintf(`
+        SELECT pin_channel_id, pin_msg_id, kind, webhook_id
+        FROM pin_messages_%s
+        WHERE message_id = ? AND board = ?
+        ORDER BY rowid`, guild_id)
+    rows, err := db.Instance.QueryContext(context.Background(), query, message_id, board)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var msgs []*PinMessage
+    for rows.Next() {
+        var m PinMessage
+        if err := rows.Scan(&m.ChannelID, &m.MessageID, &m.Kind, &m.WebhookID); err != nil {
+            return nil, err
+        }
+        msgs = append(msgs, &m)
+    }
+
+    return msgs, rows.Err()
+}
+
+// RemovePin deletes the pin on a board, and every message recorded for it or as its context, of message_id from the guild_id tables.
+func (db *database) RemovePin(guild_id string, board string, message_id string) error {
+    // Create guild tables if they don't exist
+    if err := db.createPinTable(guild_id); err != nil {
+        return err
+    }
+    if err := db.createPinMessageTable(guild_id); err != nil {
+        return err
+    }
+    if err := db.createPinContextTable(guild_id); err != nil {
+        return err
+    }
+
+    query := fmt.Sprintf(`DELETE FROM pins_%s WHERE message_id = ? AND board = ?`, guild_id)
+    _, err := db.Instance.ExecContext(context.Background(), query, message_id, board)
+    if err != nil {
+        return fmt.Errorf("Failed to delete from table: %w", err)
+    }
+
+    query = fmt.Sprintf(`DELETE FROM pin_messages_%s WHERE message_id = ? AND board = ?`, guild_id)
+    _, err = db.Instance.ExecContext(context.Background(), query, message_id, board)
+    if err != nil {
+        return fmt.Errorf("Failed to delete from table: %w", err)
+    }
+
+    query = fmt.Sprintf(`DELETE FROM pin_context_%s WHERE message_id = ? AND board = ?`, guild_id)
+    _, err = db.Instance.ExecContext(context.Background(), query, message_id, board)
+    if err != nil {
+        return fmt.Errorf("Failed to delete from table: %w", err)
+    }
+    return nil
+}
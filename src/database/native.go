@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// createNativePinTable creates a channel_id -> natively pinned message ids table.
+func (db *database) createNativePinTable() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS native_pins (
+            channel_id TEXT NOT NULL PRIMARY KEY,
+            message_ids TEXT NOT NULL
+        )
+    `
+    _, err := db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to create native_pins table: %w", err)
+    }
+    return nil
+}
+
+// SetNativePins records the ids of every message natively pinned in a channel.
+func (db *database) SetNativePins(channel_id string, message_ids []string) error {
+    // Create native pins table if it doesn't exist
+    err := db.createNativePinTable()
+    if err != nil {
+        return err
+    }
+
+    raw, err := json.Marshal(message_ids)
+    if err != nil {
+        return err
+    }
+
+    _, err = db.Instance.ExecContext(context.Background(),
+        `INSERT OR REPLACE INTO native_pins (channel_id, message_ids) VALUES (?, ?)`, channel_id, string(raw))
+    if err != nil {
+        return fmt.Errorf("Failed to insert into table: %w", err)
+    }
+    return nil
+}
+
+// GetNativePins retrieves the ids of every message natively pinned in a channel when it was last recorded.
+// Returns sql.ErrNoRows if the channel was never recorded.
+func (db *database) GetNativePins(channel_id string) ([]string, error) {
+    // Create native pins table if it doesn't exist
+    err := db.createNativePinTable()
+    if err != nil {
+        return nil, err
+    }
+
+    var raw string
+    err = db.Instance.QueryRowContext(context.Background(),
+        `SELECT message_ids FROM native_pins WHERE channel_id = ?`, channel_id).Scan(&raw)
+    if err != nil {
+        return nil, err
+    }
+
+    var message_ids []string
+    err = json.Unmarshal([]byte(raw), &message_ids)
+    return message_ids, err
+}
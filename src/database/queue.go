@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type QueuedPin struct {
+    ID int64
+    GuildID string
+    Board string
+    ChannelID string
+    MessageID string
+
+    // Last message of the conversation this request pins, "" if it pins a single message
+    LastID string
+
+    Attempts int
+    NextAttempt time.Time
+}
+
+// createQueueTable creates a table of pin requests that have not been executed yet.
+func (db *database) createQueueTable() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS queue (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            guild_id TEXT NOT NULL,
+            board TEXT NOT NULL,
+            channel_id TEXT NOT NULL,
+            message_id TEXT NOT NULL,
+            last_id TEXT NOT NULL DEFAULT '',
+            attempts INTEGER NOT NULL DEFAULT 0,
+            next_attempt INTEGER NOT NULL DEFAULT 0
+        )
+    `
+    _, err := db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to create queue table: %w", err)
+    }
+
+    // Tables created before conversations were queued lack this column
+    return db.addColumn("queue", "last_id", "TEXT NOT NULL DEFAULT ''")
+}
+
+// AddQueued inserts a pending pin request into the queue table, returning its id.
+// last_id is the last message of the conversation the request pins, or "" if it pins a single message.
+func (db *database) AddQueued(guild_id string, board string, channel_id string, message_id string, last_id string) (int64, error) {
+    // Create queue table if it doesn't exist
+    err := db.createQueueTable()
+    if err != nil {
+        return 0, err
+    }
+
+    res, err := db.Instance.ExecContext(context.Background(),
+        `INSERT INTO queue (guild_id, board, channel_id, message_id, last_id) VALUES (?, ?, ?, ?, ?)`,
+        guild_id, board, channel_id, message_id, last_id)
+    if err != nil {
+        return 0, fmt.Errorf("Failed to insert into table: %w", err)
+    }
+    return res.LastInsertId()
+}
+
+// GetQueued retrieves every pending pin request, in the order they were queued.
+func (db *database) GetQueued() ([]*QueuedPin, error) {
+    // Create queue table if it doesn't exist
+    err := db.createQueueTable()
+    if err != nil {
+        return nil, err
+    }
+
+    rows, err := db.Instance.QueryContext(context.Background(), `
+        SELECT id, guild_id, board, channel_id, message_id, last_id, attempts, next_attempt
+        FROM queue
+        ORDER BY id`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var queued []*QueuedPin
+    for rows.Next() {
+        var q QueuedPin
+        var next_attempt int64
+        if err := rows.Scan(&q.ID, &q.GuildID, &q.Board, &q.ChannelID, &q.MessageID, &q.LastID, &q.Attempts, &next_attempt); err != nil {
+            return nil, err
+        }
+        q.NextAttempt = time.Unix(next_attempt, 0)
+        queued = append(queued, &q)
+    }
+
+    return queued, rows.Err()
+}
+
+// RetryQueued records a failed attempt of a pending pin request, and when it should next be attempted.
+func (db *database) RetryQueued(id int64, attempts int, next_attempt time.Time) error {
+    // Create queue table if it doesn't exist
+    err := db.createQueueTable()
+    if err != nil {
+        return err
+    }
+
+    _, err = db.Instance.ExecContext(context.Background(),
+        `UPDATE queue SET attempts = ?, next_attempt = ? WHERE id = ?`,
+        attempts, next_attempt.Unix(), id)
+    if err != nil {
+        return fmt.Errorf("Failed to update table: %w", err)
+    }
+    return nil
+}
+
+// RemoveQueued deletes a pin request from the queue table.
+func (db *database) RemoveQueued(id int64) error {
+    // Create queue table if it doesn't exist
+    err := db.createQueueTable()
+    if err != nil {
+        return err
+    }
+
+    _, err = db.Instance.ExecContext(context.Background(), `DELETE FROM queue WHERE id = ?`, id)
+    if err != nil {
+        return fmt.Errorf("Failed to delete from table: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// createLastSeenTable creates a table holding the last time the bot was known to be online.
+func (db *database) createLastSeenTable() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS last_seen (
+            id INTEGER PRIMARY KEY CHECK (id = 0),
+            time INTEGER NOT NULL
+        )
+    `
+    _, err := db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to create last_seen table: %w", err)
+    }
+    return nil
+}
+
+// SetLastSeen records the last time the bot was known to be online.
+func (db *database) SetLastSeen(t time.Time) error {
+    // Create last seen table if it doesn't exist
+    err := db.createLastSeenTable()
+    if err != nil {
+        return err
+    }
+
+    _, err = db.Instance.ExecContext(context.Background(),
+        `INSERT OR REPLACE INTO last_seen (id, time) VALUES (0, ?)`, t.Unix())
+    if err != nil {
+        return fmt.Errorf("Failed to insert into table: %w", err)
+    }
+    return nil
+}
+
+// GetLastSeen retrieves the last time the bot was known to be online.
+func (db *database) GetLastSeen() (time.Time, error) {
+    // Create last seen table if it doesn't exist
+    err := db.createLastSeenTable()
+    if err != nil {
+        return time.Time{}, err
+    }
+
+    var t int64
+    err = db.Instance.QueryRowContext(context.Background(), `SELECT time FROM last_seen WHERE id = 0`).Scan(&t)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return time.Unix(t, 0), nil
+}
@@ -21,18 +21,25 @@ func (db *database) createStatsTable(guild_id string) error {
     query := fmt.Sprintf(`
         CREATE TABLE IF NOT EXISTS stats_%s (
             user_id TEXT NOT NULL,
-            emoji_id TEXT NOT NULL
+            emoji_id TEXT NOT NULL,
+            message_id TEXT NOT NULL DEFAULT '',
+            board TEXT NOT NULL DEFAULT ''
         )
     `, guild_id)
     _, err := db.Instance.ExecContext(context.Background(), query, guild_id)
     if err != nil {
         return fmt.Errorf("Failed to create stats_%s table: %w", guild_id, err)
     }
-    return nil
+
+    // Tables created before statistics were tied to messages, or before boards existed, lack these columns
+    if err := db.addColumn("stats_" + guild_id, "message_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+        return err
+    }
+    return db.addColumn("stats_" + guild_id, "board", "TEXT NOT NULL DEFAULT ''")
 }
 
-// AddStat inserts a statistic into the guild_id's stats table.
-func (db *database) AddStats(guild_id string, user_id string, emoji_id string) error {
+// AddStat inserts a statistic for a board into the guild_id's stats table.
+func (db *database) AddStats(guild_id string, board string, user_id string, emoji_id string, message_id string) error {
     // Create table if it doesn't exist
     err := db.createStatsTable(guild_id)
     if err != nil {
@@ -40,16 +47,49 @@ func (db *database) AddStats(guild_id string, user_id string, emoji_id string) e
     }
 
     // Insert statistic
-    query := fmt.Sprintf(`INSERT INTO stats_%s (user_id, emoji_id) VALUES (?, ?)`, guild_id)
-    _, err = db.Instance.ExecContext(context.Background(), query, user_id, emoji_id)
+    query := fmt.Sprintf(`INSERT INTO stats_%s (user_id, emoji_id, message_id, board) VALUES (?, ?, ?, ?)`, guild_id)
+    _, err = db.Instance.ExecContext(context.Background(), query, user_id, emoji_id, message_id, board)
     if err != nil {
         return fmt.Errorf("Failed to insert into table: %w", err)
     }
     return nil
 }
 
-// GetStats returns the total number of pins, with specific emojis used, a user has received in a guild.
-func (db *database) GetStats(guild_id string, user_id string) (*UserStats, error) {
+// HasStats returns whether a statistic was recorded for the given message_id on a board.
+func (db *database) HasStats(guild_id string, board string, message_id string) (bool, error) {
+    // Create table if it doesn't exist
+    err := db.createStatsTable(guild_id)
+    if err != nil {
+        return false, err
+    }
+
+    var count int
+    query := fmt.Sprintf(`SELECT COUNT(*) FROM stats_%s WHERE message_id = ? AND board = ?`, guild_id)
+    err = db.Instance.QueryRowContext(context.Background(), query, message_id, board).Scan(&count)
+    if err != nil {
+        return false, err
+    }
+    return count > 0, nil
+}
+
+// RemoveStats deletes any statistics recorded for the given message_id on a board from the guild_id's stats table.
+func (db *database) RemoveStats(guild_id string, board string, message_id string) error {
+    // Create table if it doesn't exist
+    err := db.createStatsTable(guild_id)
+    if err != nil {
+        return err
+    }
+
+    query := fmt.Sprintf(`DELETE FROM stats_%s WHERE message_id = ? AND board = ?`, guild_id)
+    _, err = db.Instance.ExecContext(context.Background(), query, message_id, board)
+    if err != nil {
+        return fmt.Errorf("Failed to delete from table: %w", err)
+    }
+    return nil
+}
+
+// GetStats returns the total number of pins, with specific emojis used, a user has received on a board in a guild.
+func (db *database) GetStats(guild_id string, board string, user_id string) (*UserStats, error) {
     // Create guild pins table if it doesn't exist
     err := db.createStatsTable(guild_id)
     if err != nil {
@@ -63,8 +103,8 @@ func (db *database) GetStats(guild_id string, user_id string) (*UserStats, error
     query := fmt.Sprintf(`
         SELECT COUNT(*)
         FROM stats_%s
-        WHERE user_id = ?`, guild_id)
-    err = db.Instance.QueryRowContext(context.Background(), query, user_id).Scan(&count)
+        WHERE user_id = ? AND board = ?`, guild_id)
+    err = db.Instance.QueryRowContext(context.Background(), query, user_id, board).Scan(&count)
     if err != nil {
         return nil, err
     }
@@ -74,10 +114,10 @@ func (db *database) GetStats(guild_id string, user_id string) (*UserStats, error
     query = fmt.Sprintf(`
         SELECT emoji_id, COUNT(*) as count
         FROM stats_%s
-        WHERE user_id = ?
+        WHERE user_id = ? AND board = ?
         GROUP BY emoji_id
         ORDER BY count DESC`, guild_id)
-    rows, err := db.Instance.QueryContext(context.Background(), query, user_id)
+    rows, err := db.Instance.QueryContext(context.Background(), query, user_id, board)
     if err != nil {
         return nil, err
     }
@@ -95,8 +135,8 @@ func (db *database) GetStats(guild_id string, user_id string) (*UserStats, error
     return stats, nil
 }
 
-// GetLeaderboard returns the top ten users in a guild with the most total number of pins, with specific emojis used.
-func (db *database) GetLeaderboard(guild_id string) ([]*UserStats, error) {
+// GetLeaderboard returns the top ten users on a board in a guild with the most total number of pins, with specific emojis used.
+func (db *database) GetLeaderboard(guild_id string, board string) ([]*UserStats, error) {
     // Create guild pins table if it doesn't exist
     err := db.createStatsTable(guild_id)
     if err != nil {
@@ -107,10 +147,11 @@ func (db *database) GetLeaderboard(guild_id string) ([]*UserStats, error) {
     query := fmt.Sprintf(`
         SELECT user_id, COUNT(*) as count
         FROM stats_%s
+        WHERE board = ?
         GROUP BY user_id
         ORDER BY count DESC
         LIMIT 10`, guild_id)
-    rows, err := db.Instance.QueryContext(context.Background(), query)
+    rows, err := db.Instance.QueryContext(context.Background(), query, board)
     if err != nil {
         return nil, err
     }
@@ -130,7 +171,7 @@ func (db *database) GetLeaderboard(guild_id string) ([]*UserStats, error) {
     // Get detailed stats for each user
     stats_list := []*UserStats{}
     for _, user_id := range users {
-        stats, err := db.GetStats(guild_id, user_id);
+        stats, err := db.GetStats(guild_id, board, user_id);
         if err != nil {
             return nil, err
         }
@@ -5,8 +5,26 @@ import (
 	"fmt"
 )
 
-// createWebhookTable creates a guild_id -> webhook pair table.
+// createWebhookTable creates a pin channel_id -> webhook pair table.
 func (db *database) createWebhookTable() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS channel_webhooks (
+            channel_id TEXT NOT NULL,
+            guild_id TEXT NOT NULL,
+            webhook_a TEXT NOT NULL,
+            webhook_b TEXT NOT NULL,
+            PRIMARY KEY (channel_id)
+        )
+    `
+    _, err := db.Instance.ExecContext(context.Background(), query)
+    if err != nil {
+        return fmt.Errorf("Failed to create channel_webhooks table: %w", err)
+    }
+    return nil
+}
+
+// createLegacyWebhookTable creates the guild_id -> webhook pair table used before pins could be routed to several channels.
+func (db *database) createLegacyWebhookTable() error {
     query := `
         CREATE TABLE IF NOT EXISTS webhooks (
             guild_id TEXT NOT NULL,
@@ -22,9 +40,9 @@ func (db *database) createWebhookTable() error {
     return nil
 }
 
-// SetWebhook creates/updates a guild_id -> webhook pair into the webhooks table.
-func (db *database) SetWebhook(guild_id string, webhook_a string, webhook_b string) error {
-    // Create guild webhooks table if it doesn't exist
+// SetWebhook creates/updates a channel_id -> webhook pair into the webhooks table.
+func (db *database) SetWebhook(guild_id string, channel_id string, webhook_a string, webhook_b string) error {
+    // Create webhooks table if it doesn't exist
     err := db.createWebhookTable()
     if err != nil {
         return err
@@ -32,11 +50,11 @@ func (db *database) SetWebhook(guild_id string, webhook_a string, webhook_b stri
 
     // Create or update row
     query := `
-        INSERT INTO webhooks (guild_id, webhook_a, webhook_b) VALUES (?, ?, ?)
-        ON CONFLICT (guild_id) DO UPDATE SET webhook_a = ?, webhook_b = ?
+        INSERT INTO channel_webhooks (channel_id, guild_id, webhook_a, webhook_b) VALUES (?, ?, ?, ?)
+        ON CONFLICT (channel_id) DO UPDATE SET webhook_a = ?, webhook_b = ?
     `
     _, err = db.Instance.ExecContext(context.Background(), query,
-        guild_id, webhook_a, webhook_b, webhook_a, webhook_b)
+        channel_id, guild_id, webhook_a, webhook_b, webhook_a, webhook_b)
     if err != nil {
         return fmt.Errorf("Failed to insert into table: %w", err)
     }
@@ -44,14 +62,37 @@ func (db *database) SetWebhook(guild_id string, webhook_a string, webhook_b stri
     return nil
 }
 
-// GetWebhook retrieves the webhook pair for a given guild_id.
-func (db *database) GetWebhook(guild_id string) (string, string, error) {
-    // Create guild webhooks table if it doesn't exist
+// GetWebhook retrieves the webhook pair for a given pin channel_id.
+func (db *database) GetWebhook(channel_id string) (string, string, error) {
+    // Create webhooks table if it doesn't exist
     err := db.createWebhookTable()
     if err != nil {
         return "", "", err
     }
 
+    // Retrieve webhook pair associated with channel_id
+    webhook_a, webhook_b := "", ""
+    err = db.Instance.QueryRowContext(
+        context.Background(),
+        "SELECT webhook_a, webhook_b FROM channel_webhooks WHERE channel_id = ?", channel_id,
+    ).Scan(&webhook_a, &webhook_b)
+
+    // Throw up any error
+    if err != nil || len(webhook_a) == 0 || len(webhook_b) == 0 {
+        return "", "", err
+    }
+
+    return webhook_a, webhook_b, nil
+}
+
+// GetLegacyWebhook retrieves the webhook pair stored for a given guild_id before pins could be routed to several channels.
+func (db *database) GetLegacyWebhook(guild_id string) (string, string, error) {
+    // Create legacy webhooks table if it doesn't exist
+    err := db.createLegacyWebhookTable()
+    if err != nil {
+        return "", "", err
+    }
+
     // Retrieve webhook pair associated with guild_id
     webhook_a, webhook_b := "", ""
     err = db.Instance.QueryRowContext(
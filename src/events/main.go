@@ -11,9 +11,19 @@ func RegisterAll(discord *discordgo.Session) {
 		log.Printf("%v#%v is online. Press CTRL + C to exit.", d.State.User.Username, d.State.User.Discriminator)
 	})
 
+    // Catch up on reactions missed while offline
+    discord.AddHandler(onReady)
+    discord.AddHandler(onDisconnect)
+
     // Register event handlers
     discord.AddHandler(onReaction)
     discord.AddHandler(onReactionRemove)
     discord.AddHandler(onMessageDelete)
+    discord.AddHandler(onMessageEdit)
     discord.AddHandler(onPin)
+
+    // Retry creating webhooks where the bot was refused, once permissions change
+    discord.AddHandler(onChannelUpdate)
+    discord.AddHandler(onRoleUpdate)
+    discord.AddHandler(onMemberUpdate)
 }
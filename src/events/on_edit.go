@@ -0,0 +1,47 @@
+package events
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+	"github.com/jadc/redpin/misc"
+)
+
+func onMessageEdit(discord *discordgo.Session, event *discordgo.MessageUpdate) {
+    // Ignore updates that are not edits by the author (e.g. link embeds loading)
+    if event.Message == nil || event.EditedTimestamp == nil {
+        return
+    }
+
+    db := database.Connect()
+    c := db.GetConfig(event.GuildID)
+
+    // Pins are frozen snapshots unless mirroring is enabled
+    if !c.Mirror {
+        return
+    }
+
+    // Update pins quoting this message as context
+    misc.RefreshContext(discord, event.GuildID, event.ID)
+
+    // Skip messages that are not pinned
+    boards, err := db.GetPinBoards(event.GuildID, event.ID)
+    if err != nil || len(boards) == 0 {
+        return
+    }
+
+    // Update events may be partial, so fetch the full message
+    message, err := discord.ChannelMessage(event.ChannelID, event.ID)
+    if err != nil {
+        log.Printf("Failed to fetch message '%s': %v", event.ID, err)
+        return
+    }
+
+    for _, board := range boards {
+        err = misc.EditPin(discord, event.GuildID, board, message)
+        if err != nil {
+            log.Printf("Failed to mirror edit of message '%s': %v", message.ID, err)
+        }
+    }
+}
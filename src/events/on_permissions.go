@@ -0,0 +1,22 @@
+package events
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/misc"
+)
+
+// Let the bot try creating webhooks again once permissions of a guild change
+func onChannelUpdate(discord *discordgo.Session, event *discordgo.ChannelUpdate) {
+    misc.ForgetWebhookRefusals(event.GuildID)
+}
+
+func onRoleUpdate(discord *discordgo.Session, event *discordgo.GuildRoleUpdate) {
+    misc.ForgetWebhookRefusals(event.GuildID)
+}
+
+// Only the roles of the bot itself change what it is allowed to do
+func onMemberUpdate(discord *discordgo.Session, event *discordgo.GuildMemberUpdate) {
+    if event.User != nil && discord.State.User != nil && event.User.ID == discord.State.User.ID {
+        misc.ForgetWebhookRefusals(event.GuildID)
+    }
+}
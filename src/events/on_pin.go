@@ -2,49 +2,106 @@ package events
 
 import (
 	"log"
-	"sync"
+	"slices"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
 	"github.com/jadc/redpin/misc"
 )
 
-// Hashmap of channel id to pin count
-// Used to prevent attempting to pin when a message is unpinned
-var counts = make(map[string]int)
-var countsMu sync.Mutex
+// Maximum age of the latest pin of a channel without recorded pins for it to be treated as new
+const NATIVE_PIN_MAX_AGE = time.Minute
 
-// hasPinCountIncreased updates the cached pin count for a channel 
-// and returns whether the new count is greater than the previous one.
-func hasPinCountIncreased(channelID string, count int) bool {
-    countsMu.Lock()
-    defer countsMu.Unlock()
-
-    prev, ok := counts[channelID]
-    counts[channelID] = count
+func onPin(discord *discordgo.Session, event *discordgo.ChannelPinsUpdate) {
+    db := database.Connect()
+    c := db.GetConfig(event.GuildID)
 
-	// If not cached (!ok) then pin anyway, might be a pin decrease
-    return !ok || count > prev
-}
+    // Ignore pins in pin channels
+    if misc.InPinChannel(discord, c, event.ChannelID) {
+        return
+    }
 
-func onPin(discord *discordgo.Session, event *discordgo.ChannelPinsUpdate) {
     // Get pinned messages in channel
     pins, err := discord.ChannelMessagesPinned(event.ChannelID)
     if err != nil {
         return
     }
+    current := make([]string, 0, len(pins))
+    for _, pin := range pins {
+        current = append(current, pin.ID)
+    }
+
+    // Compare with the pinned messages recorded the last time this channel changed
+    previous, err := db.GetNativePins(event.ChannelID)
+    recorded := err == nil
+    if err := db.SetNativePins(event.ChannelID, current); err != nil {
+        log.Printf("Failed to record pins of channel '%s': %v", event.ChannelID, err)
+    }
+
+    var added []*discordgo.Message
+    if recorded {
+        for _, pin := range pins {
+            if !slices.Contains(previous, pin.ID) {
+                added = append(added, pin)
+            }
+        }
+    } else if len(pins) > 0 {
+        // Without a record, only the latest pin can be told apart, and only if it just happened
+        last_pin, err := time.Parse(time.RFC3339, event.LastPinTimestamp)
+        if err == nil && time.Since(last_pin) < NATIVE_PIN_MAX_AGE {
+            added = append(added, pins[0])
+        }
+    }
+
+    for _, pin := range added {
+        onNativePin(discord, event.GuildID, c, pin)
+    }
+    for _, message_id := range previous {
+        if !slices.Contains(current, message_id) {
+            onNativeUnpin(discord, event.GuildID, c, message_id)
+        }
+    }
 
-    if !hasPinCountIncreased(event.ChannelID, len(pins)) {
+    // Unpins by the bot that could not be told apart, without a record, must not be mistaken for later ones
+    misc.ForgetUnpinned(event.ChannelID, current)
+}
+
+// onNativePin copies a natively pinned message to the default board, depending on the native pin mode
+func onNativePin(discord *discordgo.Session, guild_id string, c *database.Config, pin *discordgo.Message) {
+    if c.NativePins == database.NATIVE_IGNORE {
+        return
+    }
+
+    req, err := misc.CreatePinRequest(discord, guild_id, "", pin)
+    if err != nil {
+        log.Printf("Failed to create pin request for message '%s': %v", pin.ID, err)
         return
     }
+    misc.Queue.Push(req)
 
-    // Pin the message that was just pinned
-    real_pin := pins[0]
-    if real_pin != nil {
-        req, err := misc.CreatePinRequest(discord, event.GuildID, real_pin)
-        if err != nil {
-            log.Printf("Failed to create pin request for message '%s': %v", real_pin.ID, err)
-            return
+    // Free the slot, as the queued request holds a copy of the message
+    if c.NativePins == database.NATIVE_UNPIN {
+        if err := misc.UnpinNative(discord, pin.ChannelID, pin.ID); err != nil {
+            log.Printf("Failed to unpin message '%s': %v", pin.ID, err)
         }
-        misc.Queue.Push(req)
+    }
+}
+
+// onNativeUnpin removes the copy of a natively unpinned message from the default board, if enabled
+func onNativeUnpin(discord *discordgo.Session, guild_id string, c *database.Config, message_id string) {
+    // Unpins made by the bot to free slots keep their copy
+    if misc.UnpinnedByBot(message_id) || !c.NativeUnpin {
+        return
+    }
+
+    db := database.Connect()
+    if _, _, err := db.GetPin(guild_id, "", message_id); err != nil {
+        return
+    }
+
+    err := misc.Unpin(discord, guild_id, "", message_id)
+    if err != nil {
+        log.Printf("Failed to remove pin of unpinned message '%s': %v", message_id, err)
     }
 }
@@ -2,19 +2,13 @@ package events
 
 import (
 	"log"
-	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jadc/redpin/database"
 	"github.com/jadc/redpin/misc"
 )
 
-// Map of message id to reaction emoji API name
-// Used to prevent self-pinning (if that is disabled)
-// Cached to reduce API calls
-var selfpin = make(map[string]map[string]struct{})
-var selfpinMu sync.RWMutex
-
 func onReaction(discord *discordgo.Session, event *discordgo.MessageReactionAdd) {
     reaction := event.MessageReaction
 
@@ -31,41 +25,50 @@ func onReaction(discord *discordgo.Session, event *discordgo.MessageReactionAdd)
 
     // Update selfpin map on reaction add
     if reaction.UserID == message.Author.ID {
-        selfpinMu.Lock()
-        if selfpin[event.MessageID] == nil {
-            selfpin[event.MessageID] = make(map[string]struct{})
-        }
-        selfpin[event.MessageID][event.Emoji.APIName()] = struct{}{}
-        selfpinMu.Unlock()
+        misc.AddSelfReaction(event.MessageID, event.Emoji.APIName())
     }
 
     db := database.Connect()
     c := db.GetConfig(event.GuildID)
 
-    // Ignore reactions in pin channel
-    if reaction.ChannelID == c.Channel {
+    // Ignore reactions in pin channels
+    if misc.InPinChannel(discord, c, reaction.ChannelID) {
         return
     }
 
-    // Skip messages that are already pinned
-    if _, _, err := db.GetPin(event.GuildID, message.ID); err == nil {
+    // Consider pinning the message to each board
+    for _, board := range c.BoardNames() {
+        pinToBoard(discord, event.GuildID, board, reaction, message)
+    }
+}
+
+// pinToBoard pins a message to a board if its reactions qualify
+func pinToBoard(discord *discordgo.Session, guild_id string, board string, reaction *discordgo.MessageReaction, message *discordgo.Message) {
+    db := database.Connect()
+
+    // Skip messages that are already pinned, only updating their tally
+    if _, _, err := db.GetPin(guild_id, board, message.ID); err == nil {
+        misc.RefreshTally(discord, guild_id, message.ChannelID, message.ID)
         return
     }
 
-    // Ignore reactions in NSFW channels
-    if !c.NSFW {
-        channel, err := discord.State.Channel(reaction.ChannelID)
-        if err != nil || channel.NSFW {
-            return
-        }
+    // Apply any overrides of the channel, and ignore reactions where pinning is disabled
+    c, enabled := misc.GetChannelConfig(discord, guild_id, board, reaction.ChannelID)
+    if !enabled {
+        return
+    }
+
+    // Ignore reactions in NSFW channels, including threads of them
+    if !c.NSFW && misc.IsNSFW(discord, reaction.ChannelID) {
+        return
     }
 
-    if !shouldPin(c, message) {
+    if !misc.ShouldPin(discord, c, message) {
         return
     }
 
     // If reaching this far, pin the message
-    req, err := misc.CreatePinRequest(discord, event.GuildID, message)
+    req, err := misc.CreatePinRequest(discord, guild_id, board, message)
     if err != nil {
         log.Printf("Failed to create pin request for message '%s': %v", message.ID, err)
         return
@@ -73,19 +76,31 @@ func onReaction(discord *discordgo.Session, event *discordgo.MessageReactionAdd)
     misc.Queue.Push(req)
 
     // Update stats for author of message getting pinned
-    err = db.AddStats(event.GuildID, message.Author.ID, reaction.Emoji.MessageFormat())
+    err = db.AddStats(guild_id, board, message.Author.ID, reaction.Emoji.MessageFormat(), message.ID)
     if err != nil {
         log.Printf("Failed to update statistics: %v", err)
         return
     }
 }
 
-// Update selfpin map on reaction remove
+// Update selfpin map on reaction remove, and retract pins that no longer qualify
 func onReactionRemove(discord *discordgo.Session, event *discordgo.MessageReactionRemove) {
-    selfpinMu.RLock()
-    _, exists := selfpin[event.MessageID]
-    selfpinMu.RUnlock()
-    if !exists {
+    exists := misc.HasSelfReactions(event.MessageID)
+
+    db := database.Connect()
+    c := db.GetConfig(event.GuildID)
+
+    // Update tally of messages that are pinned
+    boards, err := db.GetPinBoards(event.GuildID, event.MessageID)
+    if err != nil {
+        log.Printf("Failed to fetch boards of message '%s': %v", event.MessageID, err)
+    }
+    pinned := len(boards) > 0
+    if pinned {
+        misc.RefreshTally(discord, event.GuildID, event.ChannelID, event.MessageID)
+    }
+
+    if !exists && !(pinned && c.RetractWindow > 0) {
         return
     }
 
@@ -96,51 +111,56 @@ func onReactionRemove(discord *discordgo.Session, event *discordgo.MessageReacti
         return
     }
 
-    if reaction.UserID == message.Author.ID {
-        selfpinMu.Lock()
-        if selfpin[event.MessageID] != nil {
-            delete(selfpin[event.MessageID], event.Emoji.APIName())
+    if exists && reaction.UserID == message.Author.ID {
+        misc.RemoveSelfReaction(event.MessageID, event.Emoji.APIName())
+    }
+
+    if pinned && c.RetractWindow > 0 {
+        for _, board := range boards {
+            // Apply any overrides of the channel
+            bc, _ := misc.GetChannelConfig(discord, event.GuildID, board, reaction.ChannelID)
+            retractPin(discord, event.GuildID, board, bc, message)
         }
-        selfpinMu.Unlock()
     }
 }
 
-// Update selfpin map on message delete
-func onMessageDelete(discord *discordgo.Session, event *discordgo.MessageDelete) {
-    selfpinMu.Lock()
-    delete(selfpin, event.Message.ID)
-    selfpinMu.Unlock()
-}
+// retractPin unpins a message from a board if it was pinned by reactions within the retract window,
+// and it no longer has enough reactions to be pinned
+func retractPin(discord *discordgo.Session, guild_id string, board string, c *database.Config, message *discordgo.Message) {
+    db := database.Connect()
 
-// shouldPin checks all reactions of the messsage, and determines if the message should be pinned.
-func shouldPin(c *database.Config, message *discordgo.Message) bool {
-    for _, r := range message.Reactions {
-        // If allowlist is non-empty, only then filter emojis
-        if len(c.Allowlist) > 0 {
-            // Ignore reactions not in the allowlist hashset
-            if _, ok := c.Allowlist[r.Emoji.APIName()]; !ok {
-                continue
-            }
-        }
+    // Skip messages that are not pinned
+    _, pin_msg_id, err := db.GetPin(guild_id, board, message.ID)
+    if err != nil {
+        return
+    }
 
-        count := r.Count
+    // Skip pins older than the retract window
+    pinned_at, err := discordgo.SnowflakeTimestamp(pin_msg_id)
+    if err != nil || time.Since(pinned_at) > time.Duration(c.RetractWindow) * time.Minute {
+        return
+    }
 
-        // Remove reactions from the message author from the count
-        if !c.Selfpin {
-            selfpinMu.RLock()
-            _, isSelfpin := selfpin[message.ID][r.Emoji.APIName()]
-            selfpinMu.RUnlock()
-            if isSelfpin {
-                count--
-            }
-        }
+    // Only pins made by reactions have statistics; leave manual and reply pins alone
+    if ok, err := db.HasStats(guild_id, board, message.ID); err != nil || !ok {
+        return
+    }
 
-        // Pin messages with any reactions geq the threshold
-        if count >= c.Threshold {
-            return true
-        }
+    if misc.ShouldPin(discord, c, message) {
+        return
     }
 
-    return false
+    err = misc.Unpin(discord, guild_id, board, message.ID)
+    if err != nil {
+        log.Printf("Failed to retract pin for message '%s': %v", message.ID, err)
+    }
 }
 
+// Update selfpin map on message delete, and pins quoting the message as context if pins are mirrored
+func onMessageDelete(discord *discordgo.Session, event *discordgo.MessageDelete) {
+    misc.ForgetSelfReactions(event.Message.ID)
+
+    if database.Connect().GetConfig(event.GuildID).Mirror {
+        misc.RefreshContext(discord, event.GuildID, event.Message.ID)
+    }
+}
@@ -0,0 +1,20 @@
+package events
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/misc"
+)
+
+// Catch up on every guild when connecting, as reactions while disconnected were never seen
+func onReady(discord *discordgo.Session, event *discordgo.Ready) {
+    guild_ids := make([]string, 0, len(event.Guilds))
+    for _, guild := range event.Guilds {
+        guild_ids = append(guild_ids, guild.ID)
+    }
+    misc.SetOnline(discord, guild_ids)
+}
+
+// Stop recording the bot as online while disconnected
+func onDisconnect(discord *discordgo.Session, event *discordgo.Disconnect) {
+    misc.SetOffline()
+}
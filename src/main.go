@@ -2,7 +2,10 @@ package main
 
 import (
     "os"
+    "os/signal"
     "log"
+    "strconv"
+    "syscall"
 
     "github.com/bwmarrin/discordgo"
     "github.com/jadc/redpin/events"
@@ -23,6 +26,16 @@ func main(){
         log.Fatal("Failed to create Discord session: ", err)
     }
 
+    // Create pin queue before events can push to it
+    misc.Queue = misc.NewQueue()
+
+    // Resume any pin requests that were pending when the bot stopped, before events can push new ones
+    // Otherwise, requests pushed in the meantime would be loaded again and dropped as already being pinned
+    err = misc.Queue.Load(discord)
+    if err != nil {
+        log.Print("Failed to resume pin requests: ", err)
+    }
+
     // Register event handlers
     events.RegisterAll(discord)
 
@@ -39,13 +52,26 @@ func main(){
         log.Print("Failed to register custom commands: ", err)
     }
 
-    // Constantly check and consume new pin requests
-    misc.Queue = misc.NewQueue()
-    for {
-        log.Print("Listening for pin requests...")
-        _, _, err := misc.Queue.Execute(discord)
-        if err != nil {
-            log.Printf("Error thrown during queue pop: %v", err)
+    // Consume pin requests with a pool of workers
+    workers := 4
+    if x := os.Getenv("PIN_WORKERS"); x != "" {
+        n, err := strconv.Atoi(x)
+        if err != nil || n < 1 {
+            log.Fatal("Environmental variable 'PIN_WORKERS' must be a positive integer.")
         }
+        log.Println("Using environmental variable 'PIN_WORKERS' for number of pin workers.")
+        workers = n
     }
+    misc.Queue.Start(discord, workers)
+
+    // Record when the bot was last online, to catch up on what it missed after downtime
+    go misc.Heartbeat()
+
+    // Wait until interrupted, then let pins in progress finish before closing the session
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+    <-stop
+    log.Print("Shutting down, waiting for pins in progress...")
+    misc.Queue.Stop()
+    misc.SetOffline()
 }
@@ -0,0 +1,253 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Time waited between pages of messages fetched by a backfill
+// discordgo already waits out rate limits, this leaves room in them for pins and commands
+var BACKFILL_PAGE_DELAY = time.Second
+
+var SCAN_RUNNING = errors.New("A backfill or import is already running in this server")
+
+// Hashset of guilds currently being scanned by a backfill or import
+// Only one scan may run per guild, as both can queue a lot of pins
+var scanning = make(map[string]struct{})
+var scanningMu sync.Mutex
+
+// startScan marks a guild as currently being scanned.
+// Returns false if it was already being scanned.
+func startScan(guild_id string) bool {
+    scanningMu.Lock()
+    defer scanningMu.Unlock()
+
+    if _, ok := scanning[guild_id]; ok {
+        return false
+    }
+    scanning[guild_id] = struct{}{}
+    return true
+}
+
+// doneScan removes a guild from the currently-scanning set.
+func doneScan(guild_id string) {
+    scanningMu.Lock()
+    delete(scanning, guild_id)
+    scanningMu.Unlock()
+}
+
+type BackfillMatch struct {
+    Board string
+    Message *discordgo.Message
+}
+
+type BackfillProgress struct {
+    // Channel currently being scanned, and how many channels have been scanned out of the total
+    Channel string
+    ChannelsDone int
+    ChannelsTotal int
+
+    // Number of messages scanned so far, and the messages that qualify to be pinned
+    Scanned int
+    Matches []*BackfillMatch
+}
+
+// Backfill scans the history of the given channels (or every text channel if none are given) between two times,
+// and queues every message that would have been pinned by its reactions, in chronological order.
+// Zero times leave the range unbounded; progress is called after every page of messages.
+// If dry_run is set, matches are only returned, not queued.
+func Backfill(discord *discordgo.Session, guild_id string, channels []string, after time.Time, before time.Time, dry_run bool, progress func(*BackfillProgress)) (*BackfillProgress, error) {
+    if !startScan(guild_id) {
+        return nil, SCAN_RUNNING
+    }
+    defer doneScan(guild_id)
+
+    db := database.Connect()
+    c := db.GetConfig(guild_id)
+
+    // Default to every text channel that is not a pin channel
+    if len(channels) == 0 {
+        all, err := discord.GuildChannels(guild_id)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to fetch channels: %v", err)
+        }
+        for _, channel := range all {
+            if channel.Type != discordgo.ChannelTypeGuildText && channel.Type != discordgo.ChannelTypeGuildNews {
+                continue
+            }
+            if c.IsPinChannel(channel.ID) {
+                continue
+            }
+            channels = append(channels, channel.ID)
+        }
+    }
+
+    p := &BackfillProgress{ ChannelsTotal: len(channels) }
+    for _, channel_id := range channels {
+        p.Channel = channel_id
+        err := backfillChannel(discord, guild_id, channel_id, after, before, p, progress)
+        if err != nil {
+            log.Printf("Failed to backfill channel '%s': %v", channel_id, err)
+        }
+        p.ChannelsDone += 1
+    }
+
+    // Pin in the order the messages were sent, regardless of channel
+    slices.SortStableFunc(p.Matches, func(a, b *BackfillMatch) int {
+        return a.Message.Timestamp.Compare(b.Message.Timestamp)
+    })
+
+    if dry_run {
+        return p, nil
+    }
+
+    for _, match := range p.Matches {
+        req, err := CreatePinRequest(discord, guild_id, match.Board, match.Message)
+        if err != nil {
+            log.Printf("Failed to create pin request for message '%s': %v", match.Message.ID, err)
+            continue
+        }
+        Queue.Push(req)
+
+        // Update stats for author of message getting pinned, crediting the most used emoji
+        bc, _ := GetChannelConfig(discord, guild_id, match.Board, match.Message.ChannelID)
+        if emoji := topReaction(bc, match.Message); emoji != "" {
+            err = db.AddStats(guild_id, match.Board, match.Message.Author.ID, emoji, match.Message.ID)
+            if err != nil {
+                log.Printf("Failed to update statistics: %v", err)
+            }
+        }
+    }
+
+    return p, nil
+}
+
+// backfillChannel pages through the messages of a channel from oldest to newest, adding those that qualify to the progress
+func backfillChannel(discord *discordgo.Session, guild_id string, channel_id string, after time.Time, before time.Time, p *BackfillProgress, progress func(*BackfillProgress)) error {
+    db := database.Connect()
+
+    // Resolve the config of each board once for this channel, skipping boards that are disabled in it
+    if _, err := getChannel(discord, channel_id); err != nil {
+        return err
+    }
+    nsfw := IsNSFW(discord, channel_id)
+    configs := make(map[string]*database.Config)
+    for _, board := range db.GetConfig(guild_id).BoardNames() {
+        bc, enabled := GetChannelConfig(discord, guild_id, board, channel_id)
+        if !enabled || (nsfw && !bc.NSFW) {
+            continue
+        }
+        configs[board] = bc
+    }
+    if len(configs) == 0 {
+        return nil
+    }
+
+    cursor := snowflakeAt(after)
+    for {
+        page, err := discord.ChannelMessages(channel_id, 100, "", cursor, "")
+        if err != nil {
+            return err
+        }
+
+        // Pages are sorted newest first
+        for _, message := range slices.Backward(page) {
+            cursor = message.ID
+            if !before.IsZero() && message.Timestamp.After(before) {
+                return nil
+            }
+            p.Scanned += 1
+
+            if _, ok := VALID_MSG_TYPE[message.Type]; !ok || len(message.Reactions) == 0 {
+                continue
+            }
+            message.GuildID = guild_id
+
+            for board, bc := range configs {
+                if _, _, err := db.GetPin(guild_id, board, message.ID); err == nil {
+                    continue
+                }
+                if qualifies(discord, bc, message) {
+                    p.Matches = append(p.Matches, &BackfillMatch{ Board: board, Message: message })
+                }
+            }
+        }
+
+        if progress != nil {
+            progress(p)
+        }
+        if len(page) < 100 {
+            return nil
+        }
+        time.Sleep(BACKFILL_PAGE_DELAY)
+    }
+}
+
+// qualifies checks whether a message from history should be pinned
+// Reactions that happened while the bot was offline were never seen, so self-reactions are looked up first
+func qualifies(discord *discordgo.Session, c *database.Config, message *discordgo.Message) bool {
+    if !ShouldPin(discord, c, message) {
+        return false
+    }
+    if c.Selfpin || HasSelfReactions(message.ID) || message.Author == nil {
+        return true
+    }
+
+    // Reactors are sorted by id, so the first reactor after the author's id minus one is the author if they reacted
+    author_id, err := strconv.ParseUint(message.Author.ID, 10, 64)
+    if err != nil {
+        return true
+    }
+    after := strconv.FormatUint(author_id - 1, 10)
+    for _, r := range message.Reactions {
+        if !c.Allowlist.Allows(r.Emoji.APIName()) {
+            continue
+        }
+        users, err := discord.MessageReactions(message.ChannelID, message.ID, r.Emoji.APIName(), 1, "", after)
+        if err == nil && len(users) > 0 && users[0].ID == message.Author.ID {
+            AddSelfReaction(message.ID, r.Emoji.APIName())
+        }
+    }
+
+    return ShouldPin(discord, c, message)
+}
+
+// topReaction returns the allowed emoji a message has the most reactions of, in message format
+func topReaction(c *database.Config, message *discordgo.Message) string {
+    top := topAllowed(c, message)
+    if top == nil {
+        return ""
+    }
+    return top.Emoji.MessageFormat()
+}
+
+// topAllowed returns the reactions of the allowed emoji a message has the most reactions of
+func topAllowed(c *database.Config, message *discordgo.Message) *discordgo.MessageReactions {
+    var top *discordgo.MessageReactions
+    for _, r := range message.Reactions {
+        if !c.Allowlist.Allows(r.Emoji.APIName()) {
+            continue
+        }
+        if top == nil || r.Count > top.Count {
+            top = r
+        }
+    }
+    return top
+}
+
+// snowflakeAt returns the smallest snowflake that could have been created at the given time
+func snowflakeAt(t time.Time) string {
+    const DISCORD_EPOCH = 1420070400000
+    if t.IsZero() || t.UnixMilli() < DISCORD_EPOCH {
+        return "0"
+    }
+    return strconv.FormatUint(uint64(t.UnixMilli() - DISCORD_EPOCH) << 22, 10)
+}
@@ -0,0 +1,137 @@
+package misc
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Time between records of the bot being online
+var HEARTBEAT_INTERVAL = time.Minute
+
+// Heartbeats that must be missed before reconnecting catches up, so brief reconnects do not rescan every channel
+// Starting the bot always catches up, as events were missed no matter how short it was stopped for
+var CATCHUP_MISSED_HEARTBEATS = 3
+
+// Hashset of guilds currently being caught up on
+// Ready fires again on every reconnect, which must not start a second catch-up of a guild alongside the first
+var catchingUp = make(map[string]struct{})
+var catchingUpMu sync.Mutex
+
+// Whether the bot is connected to the gateway, and so seeing every reaction,
+// and whether it has connected before since it was started
+var online bool
+var connected bool
+var onlineMu sync.Mutex
+
+// Heartbeat periodically records the current time as the last time the bot was online, while it is connected
+func Heartbeat() {
+    for range time.Tick(HEARTBEAT_INTERVAL) {
+        onlineMu.Lock()
+        if online {
+            if err := database.Connect().SetLastSeen(time.Now()); err != nil {
+                log.Printf("Failed to record last seen time: %v", err)
+            }
+        }
+        onlineMu.Unlock()
+    }
+}
+
+// SetOnline marks the bot as connected, and catches up on the guilds it missed reactions in while it was not
+func SetOnline(discord *discordgo.Session, guild_ids []string) {
+    onlineMu.Lock()
+    db := database.Connect()
+    last_seen, err := db.GetLastSeen()
+    online = true
+    reconnect := connected
+    connected = true
+    onlineMu.Unlock()
+
+    if err != nil {
+        log.Printf("No record of when the bot was last online, skipping catch-up: %v", err)
+        return
+    }
+    if reconnect && time.Since(last_seen) < time.Duration(CATCHUP_MISSED_HEARTBEATS) * HEARTBEAT_INTERVAL {
+        return
+    }
+
+    // Scan in the background, as Ready handlers block the session until they return
+    go func() {
+        for _, guild_id := range guild_ids {
+            CatchUp(discord, guild_id, last_seen)
+        }
+    }()
+}
+
+// SetOffline marks the bot as disconnected, recording the time it was last online
+func SetOffline() {
+    onlineMu.Lock()
+    defer onlineMu.Unlock()
+
+    if !online {
+        return
+    }
+    online = false
+    if err := database.Connect().SetLastSeen(time.Now()); err != nil {
+        log.Printf("Failed to record last seen time: %v", err)
+    }
+}
+
+// CatchUp pins messages of a guild that qualified while the bot was offline
+// Only messages sent within the catch-up window before the bot went offline, in channels active since then, are scanned
+func CatchUp(discord *discordgo.Session, guild_id string, last_seen time.Time) {
+    catchingUpMu.Lock()
+    if _, ok := catchingUp[guild_id]; ok {
+        catchingUpMu.Unlock()
+        log.Printf("Already catching up on guild '%s'", guild_id)
+        return
+    }
+    catchingUp[guild_id] = struct{}{}
+    catchingUpMu.Unlock()
+
+    defer func() {
+        catchingUpMu.Lock()
+        delete(catchingUp, guild_id)
+        catchingUpMu.Unlock()
+    }()
+
+    c := database.Connect().GetConfig(guild_id)
+    if c.CatchupWindow <= 0 {
+        return
+    }
+    after := last_seen.Add(-time.Duration(c.CatchupWindow) * time.Hour)
+
+    all, err := discord.GuildChannels(guild_id)
+    if err != nil {
+        log.Printf("Failed to fetch channels of guild '%s' for catch-up: %v", guild_id, err)
+        return
+    }
+
+    // Skip channels without any messages since then
+    var channels []string
+    for _, channel := range all {
+        if channel.Type != discordgo.ChannelTypeGuildText && channel.Type != discordgo.ChannelTypeGuildNews {
+            continue
+        }
+        if c.IsPinChannel(channel.ID) || channel.LastMessageID == "" {
+            continue
+        }
+        if t, err := discordgo.SnowflakeTimestamp(channel.LastMessageID); err != nil || t.Before(after) {
+            continue
+        }
+        channels = append(channels, channel.ID)
+    }
+    if len(channels) == 0 {
+        return
+    }
+
+    p, err := Backfill(discord, guild_id, channels, after, time.Time{}, false, nil)
+    if err != nil {
+        log.Printf("Failed to catch up on guild '%s': %v", guild_id, err)
+        return
+    }
+    log.Printf("Caught up on %d messages in guild '%s', queued %d pins", p.Scanned, guild_id, len(p.Matches))
+}
@@ -0,0 +1,61 @@
+package misc
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// getChannel returns the channel with the given ID, preferring the cached channel
+func getChannel(discord *discordgo.Session, channel_id string) (*discordgo.Channel, error) {
+    if channel, err := discord.State.Channel(channel_id); err == nil {
+        return channel, nil
+    }
+    return discord.Channel(channel_id)
+}
+
+// ChannelHierarchy returns the given channel followed by its parents, from most to least specific
+// e.g. a thread, the channel it is in, and the category that channel is in
+func ChannelHierarchy(discord *discordgo.Session, channel_id string) []string {
+    hierarchy := []string{ channel_id }
+
+    for len(hierarchy) < 3 {
+        channel, err := getChannel(discord, hierarchy[len(hierarchy)-1])
+        if err != nil || channel.ParentID == "" {
+            break
+        }
+        hierarchy = append(hierarchy, channel.ParentID)
+    }
+
+    return hierarchy
+}
+
+// InPinChannel returns whether the given channel is a pin channel, or a thread in one (e.g. a forum post)
+func InPinChannel(discord *discordgo.Session, c *database.Config, channel_id string) bool {
+    if c.IsPinChannel(channel_id) {
+        return true
+    }
+    channel, err := getChannel(discord, channel_id)
+    return err == nil && channel.IsThread() && c.IsPinChannel(channel.ParentID)
+}
+
+// IsNSFW returns whether the given channel is age-restricted, which threads inherit from their parent channel
+// Channels that cannot be fetched are assumed to be
+func IsNSFW(discord *discordgo.Session, channel_id string) bool {
+    channel, err := getChannel(discord, channel_id)
+    if err != nil {
+        return true
+    }
+    if channel.IsThread() {
+        parent, err := getChannel(discord, channel.ParentID)
+        return err != nil || parent.NSFW
+    }
+    return channel.NSFW
+}
+
+// GetChannelConfig returns the config of a guild for a board with the overrides and routes of the given channel
+// (and its parents) applied, and whether pinning to the board is enabled in the channel
+func GetChannelConfig(discord *discordgo.Session, guild_id string, board string, channel_id string) (*database.Config, bool) {
+    c, ok := database.Connect().GetConfig(guild_id).Board(board)
+    r, enabled := c.Resolve(ChannelHierarchy(discord, channel_id)...)
+    return r, ok && enabled
+}
@@ -0,0 +1,238 @@
+package misc
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Maximum length of all text in a message made of components
+const MAX_COMPACT_TEXT = 4000
+
+// Maximum number of items in a media gallery
+const MAX_GALLERY_ITEMS = 10
+
+// sendCompact sends the pin as a single webhook message, holding the reply context, content, attachments and footer
+// in a container, followed by a button linking to the message
+func (req *PinRequest) sendCompact(discord *discordgo.Session, webhook *discordgo.Webhook, params *discordgo.WebhookParams, ref_link string) ([]*database.PinMessage, error) {
+    files, media, links, err := compactAttachments(discord, webhook.GuildID, req.message.Attachments)
+    if err != nil {
+        return nil, err
+    }
+
+    // Text is only shown through components in this layout
+    var components []discordgo.MessageComponent
+    header := headerContent(req.templateData(discord, req.message), ref_link, req.referenceDeleted, 0)
+    content := compactContent(req.message)
+    footer := footerContent(req.templateData(discord, req.message))
+    header = withContext(discord, req.guildID, req.context, header, content, links, footer)
+    if body := compactBody(header, content, links); body != "" {
+        components = append(components, &discordgo.TextDisplay{ Content: body })
+    }
+    components = append(components, media...)
+    components = append(components,
+        &discordgo.Separator{},
+        &discordgo.TextDisplay{ Content: footer },
+    )
+
+    compact := *params
+    compact.Content = ""
+    compact.Files = files
+    compact.Flags = discordgo.MessageFlagsIsComponentsV2
+    compact.Components = []discordgo.MessageComponent{
+        &discordgo.Container{ Components: components },
+        &discordgo.ActionsRow{
+            Components: []discordgo.MessageComponent{
+                &discordgo.Button{
+                    Label: "Jump to message",
+                    Style: discordgo.LinkButton,
+                    URL: GetMessageLink(req.guildID, req.message.ChannelID, req.message.ID),
+                },
+            },
+        },
+    }
+
+    pin_msg, err := req.send(discord, webhook, &compact)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to send compact pin: %v", err)
+    }
+
+    return []*database.PinMessage{
+        { ChannelID: pin_msg.ChannelID, MessageID: pin_msg.ID, Kind: database.PART_COMPACT, WebhookID: webhook.ID },
+    }, nil
+}
+
+// compactBody returns the text of a compact pin, made of the header linking to the pin it replies to, its content,
+// and links to attachments that could not be uploaded
+func compactBody(header string, content string, links []string) string {
+    var lines []string
+    if header != "" {
+        lines = append(lines, header)
+    }
+    if content != "" {
+        lines = append(lines, content)
+    }
+    return strings.Join(append(lines, links...), "\n")
+}
+
+// withContext returns the header of a compact pin preceded by as much of its context as fits beside the rest of its text
+func withContext(discord *discordgo.Session, guild_id string, context []*discordgo.Message, header string, content string, links []string, footer string) string {
+    room := MAX_COMPACT_TEXT - len([]rune(compactBody(header, content, links))) - len([]rune(footer)) - 1
+    return compactBody(contextContent(discord, guild_id, context, room), header, nil)
+}
+
+// compactContent returns the content of a message shown in its compact pin, which cannot hold embeds,
+// so any poll is written out below it
+func compactContent(message *discordgo.Message) string {
+    if message.Poll == nil {
+        return message.Content
+    }
+    return strings.TrimSpace(message.Content + "\n" + pollText(message.Poll))
+}
+
+// compactAttachments downloads as many attachments as fit in one message, returning them as files along with the
+// components showing them (images and videos in a gallery, everything else as files), and links to the rest
+func compactAttachments(discord *discordgo.Session, guild_id string, attachments []*discordgo.MessageAttachment) ([]*discordgo.File, []discordgo.MessageComponent, []string, error) {
+    if len(attachments) == 0 {
+        return nil, nil, nil, nil
+    }
+
+    size_limit, err := sizeLimit(discord, guild_id)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+
+    var files []*discordgo.File
+    var components []discordgo.MessageComponent
+    var links []string
+    gallery := &discordgo.MediaGallery{}
+    size := 0
+
+    for n, a := range attachments {
+        if len(files) >= MAX_GALLERY_ITEMS || a.Size <= 0 || size + a.Size >= size_limit {
+            links = append(links, a.URL)
+            continue
+        }
+
+        // Download attachment, falling back to proxy URL
+        body, err := downloadAttachment(a.URL)
+        if err != nil {
+            body, err = downloadAttachment(a.ProxyURL)
+            if err != nil {
+                links = append(links, a.URL)
+                continue
+            }
+        }
+
+        // Components refer to files by name, so make names unique
+        name := fmt.Sprintf("%d_%s", n, a.Filename)
+        if strings.HasPrefix(a.Filename, "SPOILER_") {
+            name = "SPOILER_" + name
+        }
+        files = append(files, &discordgo.File{
+            Name: name,
+            ContentType: a.ContentType,
+            Reader: bytes.NewReader(body),
+        })
+        size += a.Size
+
+        media := discordgo.UnfurledMediaItem{ URL: "attachment://" + name }
+        spoiler := strings.HasPrefix(a.Filename, "SPOILER_")
+        if strings.HasPrefix(a.ContentType, "image/") || strings.HasPrefix(a.ContentType, "video/") {
+            gallery.Items = append(gallery.Items, discordgo.MediaGalleryItem{ Media: media, Spoiler: spoiler })
+        } else {
+            components = append(components, &discordgo.FileComponent{ File: media, Spoiler: spoiler })
+        }
+    }
+
+    if len(gallery.Items) > 0 {
+        components = append([]discordgo.MessageComponent{ gallery }, components...)
+    }
+    return files, components, links, nil
+}
+
+// editCompact applies a change to the container of a compact pin, keeping everything else as it is
+func editCompact(discord *discordgo.Session, part *database.PinMessage, change func(container *discordgo.Container, pin_msg *discordgo.Message)) error {
+    // Only the webhook that sent a message can edit it
+    webhook, err := GetWebhookByID(discord, part.WebhookID)
+    if err != nil {
+        return err
+    }
+
+    pin_msg, err := discord.WebhookMessage(webhook.ID, webhook.Token, part.MessageID, inThread(webhook, part)...)
+    if err != nil {
+        return fmt.Errorf("Failed to fetch pin message '%s': %v", part.MessageID, err)
+    }
+
+    var container *discordgo.Container
+    for _, component := range pin_msg.Components {
+        if c, ok := component.(*discordgo.Container); ok {
+            container = c
+            break
+        }
+    }
+    if container == nil {
+        return fmt.Errorf("Pin message '%s' has no container", part.MessageID)
+    }
+
+    change(container, pin_msg)
+    reattach(pin_msg.Components, pin_msg.Attachments)
+
+    _, err = discord.WebhookMessageEdit(webhook.ID, webhook.Token, part.MessageID, &discordgo.WebhookEdit{
+        Components: &pin_msg.Components,
+
+        // Disable pinging
+        AllowedMentions: &discordgo.MessageAllowedMentions{},
+    }, append(inThread(webhook, part), withComponents())...)
+    if err != nil {
+        return fmt.Errorf("Failed to edit pin message '%s': %v", part.MessageID, err)
+    }
+    return nil
+}
+
+// reattach points media in fetched components back to the attachments of their message, rather than
+// to the links Discord resolved them to, which expire
+func reattach(components []discordgo.MessageComponent, attachments []*discordgo.MessageAttachment) {
+    names := make(map[string]string)
+    for _, a := range attachments {
+        if u, err := url.Parse(a.URL); err == nil {
+            names[u.Path] = a.Filename
+        }
+    }
+    resolve := func(media *discordgo.UnfurledMediaItem) {
+        if u, err := url.Parse(media.URL); err == nil {
+            if name, ok := names[u.Path]; ok {
+                media.URL = "attachment://" + name
+            }
+        }
+    }
+
+    for _, component := range components {
+        switch c := component.(type) {
+            case *discordgo.Container:
+                reattach(c.Components, attachments)
+            case *discordgo.MediaGallery:
+                for n := range c.Items {
+                    resolve(&c.Items[n].Media)
+                }
+            case *discordgo.FileComponent:
+                resolve(&c.File)
+        }
+    }
+}
+
+// compactTexts returns the text displays of the container of a compact pin
+// The last one is the footer, and the first one (if there are two) is the body
+func compactTexts(container *discordgo.Container) []*discordgo.TextDisplay {
+    var texts []*discordgo.TextDisplay
+    for _, component := range container.Components {
+        if t, ok := component.(*discordgo.TextDisplay); ok {
+            texts = append(texts, t)
+        }
+    }
+    return texts
+}
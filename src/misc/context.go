@@ -0,0 +1,130 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Maximum length of each quoted message of the context of a pin
+const MAX_CONTEXT_LINE = 200
+
+// Maximum length of the value of an embed field
+const MAX_EMBED_FIELD = 1024
+
+// Maximum length of the content of a message
+const MAX_CONTENT = 2000
+
+// fetchContext returns the messages sent before a message in its channel to show as context of its pin, oldest first
+func fetchContext(discord *discordgo.Session, c *database.Config, message *discordgo.Message) []*discordgo.Message {
+    if c.ContextDepth <= 0 {
+        return nil
+    }
+
+    // Messages are returned newest first
+    msgs, err := discord.ChannelMessages(message.ChannelID, c.ContextDepth, message.ID, "", "")
+    if err != nil {
+        log.Printf("Failed to fetch context of message '%s': %v", message.ID, err)
+        return nil
+    }
+
+    var context []*discordgo.Message
+    for _, m := range msgs {
+        if c.ContextWindow > 0 && message.Timestamp.Sub(m.Timestamp) > time.Duration(c.ContextWindow) * time.Minute {
+            break
+        }
+        if _, ok := VALID_MSG_TYPE[m.Type]; !ok {
+            continue
+        }
+        context = append(context, m)
+    }
+    slices.Reverse(context)
+    return context
+}
+
+// storedContext returns the messages recorded as context of the pin of a message on a board in their current state,
+// with nil in place of those that were deleted
+func storedContext(discord *discordgo.Session, guild_id string, board string, message *discordgo.Message) []*discordgo.Message {
+    ids, err := database.Connect().GetPinContext(guild_id, board, message.ID)
+    if err != nil {
+        log.Printf("Failed to fetch context of message '%s': %v", message.ID, err)
+        return nil
+    }
+
+    var context []*discordgo.Message
+    for _, id := range ids {
+        m, err := discord.ChannelMessage(message.ChannelID, id)
+        if err != nil && !isDeletedError(err) {
+            log.Printf("Failed to fetch context message '%s': %v", id, err)
+        }
+        context = append(context, m)
+    }
+    return context
+}
+
+// contextContent returns the context of a pin as a block quoting each message on its own line,
+// noting messages that were deleted (nil). The oldest messages are left out until it fits in the given length.
+func contextContent(discord *discordgo.Session, guild_id string, context []*discordgo.Message, limit int) string {
+    var lines []string
+    for _, m := range context {
+        if m == nil {
+            lines = append(lines, "> *Deleted message*")
+            continue
+        }
+        if isForward(m) && m.MessageSnapshots[0].Message != nil {
+            snapshot := *m.MessageSnapshots[0].Message
+            snapshot.Author = m.Author
+            m = &snapshot
+        }
+
+        text := strings.Join(strings.Fields(m.Content), " ")
+        if text == "" && len(m.Attachments) > 0 {
+            text = fmt.Sprintf("*%d attachment(s)*", len(m.Attachments))
+        }
+        if runes := []rune(text); len(runes) > MAX_CONTEXT_LINE {
+            text = string(runes[:MAX_CONTEXT_LINE-1]) + "…"
+        }
+        lines = append(lines, fmt.Sprintf("> **%s**: %s", memberName(discord, guild_id, m.Author), text))
+    }
+
+    for len(lines) > 0 && len([]rune(strings.Join(lines, "\n"))) > limit {
+        lines = lines[1:]
+    }
+    return strings.Join(lines, "\n")
+}
+
+// contextIDs returns the ids of the given context messages
+func contextIDs(context []*discordgo.Message) []string {
+    var ids []string
+    for _, m := range context {
+        ids = append(ids, m.ID)
+    }
+    return ids
+}
+
+// RefreshContext updates every pin showing a message as context to reflect the message's current state
+func RefreshContext(discord *discordgo.Session, guild_id string, context_id string) {
+    db := database.Connect()
+
+    pins, err := db.GetContextPins(guild_id, context_id)
+    if err != nil {
+        log.Printf("Failed to fetch pins with context message '%s': %v", context_id, err)
+        return
+    }
+
+    for _, p := range pins {
+        message, err := discord.ChannelMessage(p.ChannelID, p.MessageID)
+        if err != nil {
+            log.Printf("Failed to fetch message '%s': %v", p.MessageID, err)
+            continue
+        }
+        if err := EditPin(discord, guild_id, p.Board, message); err != nil {
+            log.Printf("Failed to update context of pin of message '%s': %v", p.MessageID, err)
+        }
+    }
+}
@@ -0,0 +1,190 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+var (
+    // Maximum number of messages pinned together as one conversation
+    MAX_CONVERSATION = 25
+
+    // Time after starting a conversation during which it can be ended
+    CONVERSATION_TIMEOUT = 15 * time.Minute
+
+    NO_CONVERSATION = errors.New("Conversation was not started in this channel")
+    CONVERSATION_EMBED = errors.New("Conversations can only be pinned through a webhook, but this board sends pins as embeds or cannot create webhooks")
+)
+
+// conversationStart is the first message of a conversation someone is selecting
+type conversationStart struct {
+    channelID string
+    messageID string
+    startedAt time.Time
+}
+
+// Map of guild and user ids -> conversation they started selecting
+var conversations = make(map[[2]string]*conversationStart)
+var conversationsMu sync.Mutex
+
+// StartConversation marks a message as the start of a conversation selected by a user, replacing any they started before
+func StartConversation(guild_id string, user_id string, message *discordgo.Message) {
+    conversationsMu.Lock()
+    defer conversationsMu.Unlock()
+    conversations[[2]string{ guild_id, user_id }] = &conversationStart{ message.ChannelID, message.ID, time.Now() }
+}
+
+// HasConversation returns whether a user is selecting a conversation in a channel
+func HasConversation(guild_id string, user_id string, channel_id string) bool {
+    conversationsMu.Lock()
+    defer conversationsMu.Unlock()
+    start, ok := conversations[[2]string{ guild_id, user_id }]
+    return ok && start.channelID == channel_id && time.Since(start.startedAt) < CONVERSATION_TIMEOUT
+}
+
+// EndConversation returns every message of the conversation a user started selecting up to the given message, oldest first
+func EndConversation(discord *discordgo.Session, guild_id string, user_id string, message *discordgo.Message) ([]*discordgo.Message, error) {
+    if !HasConversation(guild_id, user_id, message.ChannelID) {
+        return nil, NO_CONVERSATION
+    }
+    conversationsMu.Lock()
+    start := conversations[[2]string{ guild_id, user_id }]
+    delete(conversations, [2]string{ guild_id, user_id })
+    conversationsMu.Unlock()
+
+    // The conversation may be selected in either direction
+    first, last := start.messageID, message.ID
+    if first_at, _ := discordgo.SnowflakeTimestamp(first); first_at.After(message.Timestamp) {
+        first, last = last, first
+    }
+    return fetchConversation(discord, message.ChannelID, first, last)
+}
+
+// fetchConversation returns every message of a channel from the first to the last given message, oldest first
+func fetchConversation(discord *discordgo.Session, channel_id string, first string, last string) ([]*discordgo.Message, error) {
+    first_msg, err := discord.ChannelMessage(channel_id, first)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to fetch first message of conversation: %v", err)
+    }
+    msgs := []*discordgo.Message{ first_msg }
+
+    // Messages after the first are returned newest first
+    if first != last {
+        after, err := discord.ChannelMessages(channel_id, 100, "", first, "")
+        if err != nil {
+            return nil, fmt.Errorf("Failed to fetch conversation: %v", err)
+        }
+        slices.Reverse(after)
+
+        for _, m := range after {
+            if _, ok := VALID_MSG_TYPE[m.Type]; ok {
+                msgs = append(msgs, m)
+            }
+            if m.ID == last {
+                break
+            }
+        }
+        if msgs[len(msgs)-1].ID != last {
+            return nil, fmt.Errorf("Conversation is longer than %d messages", MAX_CONVERSATION)
+        }
+    }
+
+    if len(msgs) > MAX_CONVERSATION {
+        return nil, fmt.Errorf("Conversation is longer than %d messages", MAX_CONVERSATION)
+    }
+    return msgs, nil
+}
+
+// CreateConversationRequest creates a request pinning messages of one channel to a board as one entry, recorded as
+// the pin of the first message. The messages are copied in their current state, oldest first.
+func CreateConversationRequest(discord *discordgo.Session, guild_id string, board string, messages []*discordgo.Message) (*PinRequest, error) {
+    first := messages[0]
+
+    // Conversations are only sent as webhook clones, whatever the layout of the board
+    c, _ := GetChannelConfig(discord, guild_id, board, first.ChannelID)
+    if c.Render == database.RENDER_EMBED {
+        return nil, CONVERSATION_EMBED
+    }
+    if _, err := GetWebhook(discord, guild_id, c.Channel); err != nil {
+        if isPermissionError(err) {
+            return nil, CONVERSATION_EMBED
+        }
+        return nil, fmt.Errorf("Failed to retrieve webhook: %v", err)
+    }
+
+    // Skip conversations currently being pinned
+    if !startPinning(board, first.ID) {
+        return nil, ALREADY_PINNED
+    }
+
+    // Pin what forwarded messages forward, like single messages
+    conversation := make([]*discordgo.Message, len(messages))
+    for n, m := range messages {
+        conversation[n] = expandMessage(unwrapForward(discord, guild_id, m))
+    }
+
+    log.Printf("Created new pin request for conversation of %d messages starting at '%s' in guild '%s'", len(messages), first.ID, guild_id)
+    return &PinRequest{ guildID: guild_id, board: board, message: conversation[0], conversation: conversation }, nil
+}
+
+// lastID returns the ID of the last message of the conversation a request pins, or "" if it pins a single message
+func (req *PinRequest) lastID() string {
+    if len(req.conversation) == 0 {
+        return ""
+    }
+    return req.conversation[len(req.conversation)-1].ID
+}
+
+// sendConversation sends the pin of a conversation as copies of each of its messages through a webhook, in order
+// between a single header and footer
+func (req *PinRequest) sendConversation(discord *discordgo.Session, webhook *discordgo.Webhook, params *discordgo.WebhookParams) ([]*database.PinMessage, error) {
+    var parts []*database.PinMessage
+    first := req.message
+    defer func() { req.message = first }()
+
+    // Send header naming the conversation
+    params.Content = headerContent(req.templateData(discord, first), "", false, len(req.conversation))
+    header, err := req.send(discord, webhook, params)
+    if err != nil {
+        return parts, fmt.Errorf("Failed to send conversation header: %v", err)
+    }
+    parts = append(parts, &database.PinMessage{ ChannelID: header.ChannelID, MessageID: header.ID, Kind: database.PART_HEADER, WebhookID: webhook.ID })
+
+    // Send the webhook copy of each message, as its own author, keeping whatever was sent even if it fails partway
+    // Only the first message stands for the pin; the rest are recorded so they are retracted with it
+    for n, m := range req.conversation {
+        req.message = m
+        clone, att_msgs, err := req.cloneMessage(discord, webhook, req.webhookParams(discord, m))
+
+        kind := database.PART_CONVERSATION
+        if n == 0 {
+            kind = database.PART_BODY
+        }
+        if clone != nil {
+            parts = append(parts, &database.PinMessage{ ChannelID: clone.ChannelID, MessageID: clone.ID, Kind: kind, WebhookID: webhook.ID })
+        }
+        for _, a := range att_msgs {
+            parts = append(parts, &database.PinMessage{ ChannelID: a.ChannelID, MessageID: a.ID, Kind: database.PART_ATTACHMENT, WebhookID: webhook.ID })
+        }
+        if err != nil {
+            return parts, fmt.Errorf("Failed to clone message '%s' of conversation: %v", m.ID, err)
+        }
+    }
+
+    // Send footer
+    params.Content = footerContent(req.templateData(discord, first))
+    footer, err := req.send(discord, webhook, params)
+    if err != nil {
+        return parts, fmt.Errorf("Failed to send conversation footer: %v", err)
+    }
+    parts = append(parts, &database.PinMessage{ ChannelID: footer.ChannelID, MessageID: footer.ID, Kind: database.PART_FOOTER, WebhookID: webhook.ID })
+
+    return parts, nil
+}
@@ -0,0 +1,189 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// EditPin updates the pin of a message on a board in place to reflect the message's current state
+func EditPin(discord *discordgo.Session, guild_id string, board string, message *discordgo.Message) error {
+    db := database.Connect()
+    message = expandMessage(unwrapForward(discord, guild_id, message))
+
+    parts, err := db.GetPinMessages(guild_id, board, message.ID)
+    if err != nil {
+        return fmt.Errorf("Failed to fetch pin messages for message '%s': %v", message.ID, err)
+    }
+
+    // Find the copy of the message itself
+    var body *database.PinMessage
+    for _, p := range parts {
+        if p.Kind == database.PART_BODY || p.Kind == database.PART_EMBED || p.Kind == database.PART_COMPACT {
+            body = p
+            break
+        }
+    }
+    if body != nil && body.Kind == database.PART_EMBED {
+        return editEmbed(discord, guild_id, board, body, message)
+    }
+    if body != nil && body.Kind == database.PART_COMPACT {
+        context := storedContext(discord, guild_id, board, message)
+        header := replyHeader(discord, guild_id, board, message)
+        content := compactContent(message)
+        err = editCompact(discord, body, func(container *discordgo.Container, pin_msg *discordgo.Message) {
+            links := compactLinks(message, pin_msg)
+
+            // The footer is the last text of the container
+            footer := ""
+            if texts := compactTexts(container); len(texts) > 0 {
+                footer = texts[len(texts)-1].Content
+            }
+            setCompactBody(container, compactBody(withContext(discord, guild_id, context, header, content, links, footer), content, links))
+        })
+        if err != nil {
+            return err
+        }
+        log.Printf("Updated pin of message '%s' on board '%s' in guild '%s'", message.ID, board, guild_id)
+        return nil
+    }
+    if body == nil || body.WebhookID == "" {
+        return fmt.Errorf("Pin of message '%s' was not recorded with its webhook", message.ID)
+    }
+
+    // Only the webhook that sent a message can edit it
+    webhook, err := GetWebhookByID(discord, body.WebhookID)
+    if err != nil {
+        return err
+    }
+
+    embeds := richEmbeds(message)
+    components := message.Components
+    edit := &discordgo.WebhookEdit{
+        Embeds: &embeds,
+        Components: &components,
+
+        // Disable pinging
+        AllowedMentions: &discordgo.MessageAllowedMentions{},
+    }
+
+    // Messages without content may have had their attachment links placed in the content instead
+    if message.Content != "" || len(message.Attachments) == 0 {
+        edit.Content = &message.Content
+    }
+
+    _, err = discord.WebhookMessageEdit(webhook.ID, webhook.Token, body.MessageID, edit, inThread(webhook, body)...)
+    if err != nil {
+        return fmt.Errorf("Failed to edit pin message '%s': %v", body.MessageID, err)
+    }
+
+    // Update the quote of the messages leading up to this one, which may have been edited or deleted since
+    for _, p := range parts {
+        if p.Kind != database.PART_CONTEXT {
+            continue
+        }
+        content := contextContent(discord, guild_id, storedContext(discord, guild_id, board, message), MAX_CONTENT)
+        _, err = discord.WebhookMessageEdit(webhook.ID, webhook.Token, p.MessageID, &discordgo.WebhookEdit{
+            Content: &content,
+
+            // Disable pinging
+            AllowedMentions: &discordgo.MessageAllowedMentions{},
+        }, inThread(webhook, p)...)
+        if err != nil {
+            return fmt.Errorf("Failed to edit pin context '%s': %v", p.MessageID, err)
+        }
+    }
+
+    log.Printf("Updated pin of message '%s' on board '%s' in guild '%s'", message.ID, board, guild_id)
+    return nil
+}
+
+// editEmbed updates an embed pin in place, keeping the author and any other fields of its embed
+func editEmbed(discord *discordgo.Session, guild_id string, board string, body *database.PinMessage, message *discordgo.Message) error {
+    pin_msg, err := discord.ChannelMessage(body.ChannelID, body.MessageID)
+    if err != nil {
+        return fmt.Errorf("Failed to fetch pin message '%s': %v", body.MessageID, err)
+    }
+    if len(pin_msg.Embeds) == 0 {
+        return fmt.Errorf("Pin message '%s' has no embed", body.MessageID)
+    }
+
+    embed := pin_msg.Embeds[0]
+    setEmbedMessage(embed, guild_id, message)
+    for n, f := range embed.Fields {
+        if f.Name == "Context" {
+            embed.Fields[n] = contextField(discord, guild_id, storedContext(discord, guild_id, board, message))
+        }
+    }
+    embeds := fitEmbeds(append([]*discordgo.MessageEmbed{ embed }, richEmbeds(message)...))
+
+    _, err = discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+        ID: body.MessageID,
+        Channel: body.ChannelID,
+        Embeds: &embeds,
+
+        // Disable pinging
+        AllowedMentions: &discordgo.MessageAllowedMentions{},
+    })
+    if err != nil {
+        return fmt.Errorf("Failed to edit pin message '%s': %v", body.MessageID, err)
+    }
+
+    log.Printf("Updated pin of message '%s' on board '%s' in guild '%s'", message.ID, board, guild_id)
+    return nil
+}
+
+// setCompactBody replaces the body of a compact pin, adding or removing it as needed
+func setCompactBody(container *discordgo.Container, content string) {
+    texts := compactTexts(container)
+
+    // Without a body, add one above everything else
+    if len(texts) < 2 {
+        if content != "" {
+            container.Components = append([]discordgo.MessageComponent{ &discordgo.TextDisplay{ Content: content } }, container.Components...)
+        }
+        return
+    }
+
+    // Text cannot be empty, so drop the body if nothing is left of it
+    body := texts[0]
+    body.Content = content
+    if body.Content == "" {
+        container.Components = slices.DeleteFunc(container.Components, func(c discordgo.MessageComponent) bool {
+            return c == body
+        })
+    }
+}
+
+// compactLinks returns links to the attachments of a message that were not uploaded to its compact pin
+func compactLinks(message *discordgo.Message, pin_msg *discordgo.Message) []string {
+    var links []string
+    for n, a := range message.Attachments {
+        uploaded := slices.ContainsFunc(pin_msg.Attachments, func(p *discordgo.MessageAttachment) bool {
+            return strings.HasPrefix(strings.TrimPrefix(p.Filename, "SPOILER_"), fmt.Sprintf("%d_", n))
+        })
+        if !uploaded {
+            links = append(links, a.URL)
+        }
+    }
+    return links
+}
+
+// replyHeader returns the header of the pin of a message on a board, linking to the pin of the message it replies to
+// if that is pinned there too (or noting that it was deleted), and naming the thread the message is in
+func replyHeader(discord *discordgo.Session, guild_id string, board string, message *discordgo.Message) string {
+    ref_link, ref_deleted := "", false
+    if ref := message.MessageReference; ref != nil && ref.Type != discordgo.MessageReferenceTypeForward {
+        db := database.Connect()
+        if ref_pin_channel_id, ref_pin_msg_id, err := db.GetPin(guild_id, board, ref.MessageID); err == nil {
+            ref_link = GetMessageLink(guild_id, ref_pin_channel_id, ref_pin_msg_id)
+        } else if _, err := discord.ChannelMessage(ref.ChannelID, ref.MessageID); err != nil {
+            ref_deleted = isDeletedError(err)
+        }
+    }
+    return headerContent(templateData(discord, guild_id, board, message), ref_link, ref_deleted, 0)
+}
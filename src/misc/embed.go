@@ -0,0 +1,180 @@
+package misc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Maximum length of the description of an embed
+const MAX_EMBED_DESCRIPTION = 4096
+
+// Maximum length of the text of every embed of a message combined
+const MAX_EMBED_TOTAL = 6000
+
+// sendEmbed sends the pin as a single message from the bot itself, with the message as an embed below its footer
+// Used when webhooks are not available
+func (req *PinRequest) sendEmbed(discord *discordgo.Session, channel_id string, params *discordgo.WebhookParams, ref_link string) ([]*database.PinMessage, error) {
+    embed := pinEmbed(req.guildID, req.message, params)
+    if ref_link != "" || req.referenceDeleted {
+        value := ref_link
+        if value == "" {
+            value = "A deleted message"
+        }
+        embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+            Name: "Reply to",
+            Value: value,
+            Inline: true,
+        })
+    }
+    if from := forwardedFrom(req.guildID, req.message); from != "" {
+        embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+            Name: "Forwarded from",
+            Value: from,
+            Inline: true,
+        })
+    }
+    if len(req.context) > 0 {
+        embed.Fields = append(embed.Fields, contextField(discord, req.guildID, req.context))
+    }
+    if thread, err := getChannel(discord, req.message.ChannelID); err == nil && thread.IsThread() {
+        embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+            Name: "Thread",
+            Value: thread.Mention(),
+            Inline: true,
+        })
+    }
+
+    send := &discordgo.MessageSend{
+        Content: footerContent(req.templateData(discord, req.message)),
+        Embeds: fitEmbeds(append([]*discordgo.MessageEmbed{ embed }, richEmbeds(req.message)...)),
+
+        // Disable pinging
+        AllowedMentions: &discordgo.MessageAllowedMentions{},
+    }
+
+    // Forum posts start with their first message, which shares the id of the post
+    if req.threadName != "" {
+        post, err := discord.ForumThreadStartComplex(channel_id, &discordgo.ThreadStart{ Name: req.threadName }, send)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to create forum post: %v", err)
+        }
+        req.thread = post.ID
+        return []*database.PinMessage{
+            { ChannelID: post.ID, MessageID: post.ID, Kind: database.PART_EMBED },
+        }, nil
+    }
+
+    if req.thread != "" {
+        channel_id = req.thread
+    }
+    pin_msg, err := discord.ChannelMessageSendComplex(channel_id, send)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to send pin embed: %v", err)
+    }
+
+    return []*database.PinMessage{
+        { ChannelID: pin_msg.ChannelID, MessageID: pin_msg.ID, Kind: database.PART_EMBED },
+    }, nil
+}
+
+// pinEmbed returns a starboard embed of a message, with its author, content, first image, and source channel
+func pinEmbed(guild_id string, message *discordgo.Message, params *discordgo.WebhookParams) *discordgo.MessageEmbed {
+    embed := &discordgo.MessageEmbed{
+        Author: &discordgo.MessageEmbedAuthor{
+            Name: params.Username,
+            IconURL: params.AvatarURL,
+        },
+        Timestamp: message.Timestamp.Format(time.RFC3339),
+        Fields: []*discordgo.MessageEmbedField{
+            {
+                Name: "Source",
+                Value: "<#" + message.ChannelID + ">",
+                Inline: true,
+            },
+        },
+    }
+    setEmbedMessage(embed, guild_id, message)
+    return embed
+}
+
+// setEmbedMessage fills an embed with the current content and attachments of a message
+func setEmbedMessage(embed *discordgo.MessageEmbed, guild_id string, message *discordgo.Message) {
+    link := fmt.Sprintf("[Jump to message](%s)", GetMessageLink(guild_id, message.ChannelID, message.ID))
+
+    // Show the first image in the embed, and link the other attachments
+    embed.Image = nil
+    var links []string
+    for _, a := range message.Attachments {
+        if embed.Image == nil && strings.HasPrefix(a.ContentType, "image/") {
+            embed.Image = &discordgo.MessageEmbedImage{ URL: a.URL }
+            continue
+        }
+        links = append(links, fmt.Sprintf("[%s](%s)", a.Filename, a.URL))
+    }
+
+    // Fall back to the first image of any link embeds
+    for _, e := range message.Embeds {
+        if embed.Image != nil {
+            break
+        }
+        if e.Image != nil {
+            embed.Image = &discordgo.MessageEmbedImage{ URL: e.Image.URL }
+        } else if e.Type == discordgo.EmbedTypeImage && e.Thumbnail != nil {
+            embed.Image = &discordgo.MessageEmbedImage{ URL: e.Thumbnail.URL }
+        }
+    }
+
+    suffix := "\n\n" + link
+    if len(links) > 0 {
+        suffix = "\n\n" + strings.Join(links, "\n") + suffix
+    }
+
+    content := []rune(message.Content)
+    if room := MAX_EMBED_DESCRIPTION - len([]rune(suffix)); len(content) > room {
+        content = append(content[:room-1], '…')
+    }
+    embed.Description = strings.TrimSpace(string(content) + suffix)
+}
+
+// contextField returns the embed field quoting the context of a pin, keeping its most recent messages if it is too long
+func contextField(discord *discordgo.Session, guild_id string, context []*discordgo.Message) *discordgo.MessageEmbedField {
+    return &discordgo.MessageEmbedField{ Name: "Context", Value: contextContent(discord, guild_id, context, MAX_EMBED_FIELD) }
+}
+
+// fitEmbeds keeps the embeds of a pin within the total length Discord allows in one message, leaving out the rich
+// embeds copied from the message, last first, then shortening the description of the pin embed (the first)
+func fitEmbeds(embeds []*discordgo.MessageEmbed) []*discordgo.MessageEmbed {
+    total := 0
+    for _, e := range embeds {
+        total += embedLength(e)
+    }
+    for len(embeds) > 1 && total > MAX_EMBED_TOTAL {
+        total -= embedLength(embeds[len(embeds)-1])
+        embeds = embeds[:len(embeds)-1]
+    }
+
+    if over := total - MAX_EMBED_TOTAL; over > 0 && len(embeds) > 0 {
+        description := []rune(embeds[0].Description)
+        embeds[0].Description = string(append(description[:max(len(description)-over-1, 0)], '…'))
+    }
+    return embeds
+}
+
+// embedLength returns the length of the text of an embed that counts towards the total Discord allows in one message
+func embedLength(embed *discordgo.MessageEmbed) int {
+    n := len([]rune(embed.Title)) + len([]rune(embed.Description))
+    for _, f := range embed.Fields {
+        n += len([]rune(f.Name)) + len([]rune(f.Value))
+    }
+    if embed.Footer != nil {
+        n += len([]rune(embed.Footer.Text))
+    }
+    if embed.Author != nil {
+        n += len([]rune(embed.Author.Name))
+    }
+    return n
+}
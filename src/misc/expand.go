@@ -0,0 +1,121 @@
+package misc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+    // Link to the image of a sticker, by id and file extension
+    STICKER_URL = "https://media.discordapp.net/stickers/%s.%s"
+
+    // Maximum size of a sticker image, assumed as their real size is not given
+    MAX_STICKER_SIZE = 512 * 1024
+
+    // Length of the bar showing the share of votes of each answer of a poll
+    POLL_BAR_LENGTH = 10
+
+    // Maximum length of the title of an embed
+    MAX_EMBED_TITLE = 256
+)
+
+// expandMessage returns a copy of a message with its stickers as attachments (or their names, if they are not images)
+// and its poll as an embed, so they are pinned along with the rest of the message
+// Messages with neither are returned as they are
+func expandMessage(message *discordgo.Message) *discordgo.Message {
+    if len(message.StickerItems) == 0 && message.Poll == nil {
+        return message
+    }
+
+    m := *message
+    m.Attachments = slices.Clone(message.Attachments)
+    m.Embeds = slices.Clone(message.Embeds)
+
+    for _, s := range message.StickerItems {
+        ext := "png"
+        switch s.FormatType {
+            case discordgo.StickerFormatTypeGIF:
+                ext = "gif"
+            case discordgo.StickerFormatTypeLottie:
+                // Lottie stickers are animations drawn by the client, which have no image to upload
+                m.Content = strings.TrimSpace(m.Content + "\n-# Sticker: " + s.Name)
+                continue
+        }
+        m.Attachments = append(m.Attachments, &discordgo.MessageAttachment{
+            ID: s.ID,
+            URL: fmt.Sprintf(STICKER_URL, s.ID, ext),
+            Filename: s.Name + "." + ext,
+            ContentType: "image/" + ext,
+            Size: MAX_STICKER_SIZE,
+        })
+    }
+
+    if message.Poll != nil {
+        m.Embeds = append(m.Embeds, pollEmbed(message.Poll))
+    }
+
+    return &m
+}
+
+// pollEmbed returns an embed of a poll, with its question, answers and their votes at the time
+func pollEmbed(poll *discordgo.Poll) *discordgo.MessageEmbed {
+    counts := make(map[int]int)
+    total := 0
+    final := false
+    if poll.Results != nil {
+        final = poll.Results.Finalized
+        for _, c := range poll.Results.AnswerCounts {
+            counts[c.ID] = c.Count
+            total += c.Count
+        }
+    }
+
+    var lines []string
+    for _, a := range poll.Answers {
+        text := ""
+        if a.Media != nil {
+            text = a.Media.Text
+            if e := a.Media.Emoji; e != nil {
+                if e.ID != "" {
+                    text = fmt.Sprintf("<:%s:%s> %s", e.Name, e.ID, text)
+                } else if e.Name != "" {
+                    text = e.Name + " " + text
+                }
+            }
+        }
+
+        share := 0
+        if total > 0 {
+            share = counts[a.AnswerID] * 100 / total
+        }
+        filled := share * POLL_BAR_LENGTH / 100
+        bar := strings.Repeat("█", filled) + strings.Repeat("░", POLL_BAR_LENGTH - filled)
+        lines = append(lines, fmt.Sprintf("**%s**\n`%s` %d votes (%d%%)", strings.TrimSpace(text), bar, counts[a.AnswerID], share))
+    }
+
+    status := "Results at the time of pinning"
+    if final {
+        status = "Final results"
+    }
+
+    title := []rune(":bar_chart:  " + poll.Question.Text)
+    if len(title) > MAX_EMBED_TITLE {
+        title = append(title[:MAX_EMBED_TITLE-1], '…')
+    }
+
+    return &discordgo.MessageEmbed{
+        Type: discordgo.EmbedTypeRich,
+        Title: string(title),
+        Description: strings.Join(lines, "\n"),
+        Footer: &discordgo.MessageEmbedFooter{ Text: fmt.Sprintf("%s · %d votes", status, total) },
+    }
+}
+
+// pollText returns a poll written out as text, for pins that cannot hold embeds
+func pollText(poll *discordgo.Poll) string {
+    e := pollEmbed(poll)
+    return fmt.Sprintf("### %s\n%s\n-# %s", e.Title, e.Description, e.Footer.Text)
+}
@@ -0,0 +1,51 @@
+package misc
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Maximum length of the name of a forum post
+const MAX_POST_TITLE = 100
+
+// forumTitle returns the title of the forum post of a pin, made of the first line of the message,
+// or a description of it if it has no content
+func forumTitle(message *discordgo.Message, data *TemplateData) string {
+    title := strings.TrimSpace(strings.SplitN(strings.TrimSpace(message.Content), "\n", 2)[0])
+    if title == "" {
+        kind := "Message"
+        for _, a := range message.Attachments {
+            if strings.HasPrefix(a.ContentType, "image/") {
+                kind = "Image"
+                break
+            }
+        }
+        title = kind + " by " + data.Author
+    }
+
+    if runes := []rune(title); len(runes) > MAX_POST_TITLE {
+        title = string(runes[:MAX_POST_TITLE-1]) + "…"
+    }
+    return title
+}
+
+// forumTags returns the ids of the tags of a forum mapped to the most used allowed emoji of a message
+func forumTags(c *database.Config, forum *discordgo.Channel, message *discordgo.Message) []string {
+    top := topAllowed(c, message)
+    if top == nil {
+        return nil
+    }
+    name, ok := c.ForumTags[top.Emoji.APIName()]
+    if !ok {
+        return nil
+    }
+
+    for _, tag := range forum.AvailableTags {
+        if strings.EqualFold(tag.Name, name) || tag.ID == name {
+            return []string{ tag.ID }
+        }
+    }
+    return nil
+}
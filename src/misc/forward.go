@@ -0,0 +1,54 @@
+package misc
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// isForward returns whether a message is a forward of another message
+func isForward(message *discordgo.Message) bool {
+    ref := message.MessageReference
+    return ref != nil && ref.Type == discordgo.MessageReferenceTypeForward && len(message.MessageSnapshots) > 0
+}
+
+// unwrapForward returns a copy of a forwarded message showing the content, embeds and attachments of the message
+// it forwards, credited to the original author if they can be found. Other messages are returned as they are.
+// The copy keeps the id, channel, reactions and forward reference of the forward itself.
+func unwrapForward(discord *discordgo.Session, guild_id string, message *discordgo.Message) *discordgo.Message {
+    if !isForward(message) || message.MessageSnapshots[0].Message == nil {
+        return message
+    }
+    snapshot := message.MessageSnapshots[0].Message
+
+    m := *message
+    m.Content = snapshot.Content
+    m.Embeds = snapshot.Embeds
+    m.Attachments = snapshot.Attachments
+    m.Components = snapshot.Components
+    m.StickerItems = snapshot.StickerItems
+    m.MessageSnapshots = nil
+
+    // Snapshots leave out their author, which is only known if the original can still be fetched from this guild
+    ref := message.MessageReference
+    if snapshot.Author != nil {
+        m.Author = snapshot.Author
+    } else if ref.GuildID == "" || ref.GuildID == guild_id {
+        if original, err := discord.ChannelMessage(ref.ChannelID, ref.MessageID); err == nil && original.Author != nil {
+            m.Author = original.Author
+        }
+    }
+
+    return &m
+}
+
+// forwardedFrom returns a mention of the channel a forwarded message was forwarded from,
+// or a link to the original message if it is in another guild, or "" if the message is not a forward
+func forwardedFrom(guild_id string, message *discordgo.Message) string {
+    ref := message.MessageReference
+    if ref == nil || ref.Type != discordgo.MessageReferenceTypeForward {
+        return ""
+    }
+    if ref.GuildID != "" && ref.GuildID != guild_id {
+        return GetMessageLink(ref.GuildID, ref.ChannelID, ref.MessageID)
+    }
+    return "<#" + ref.ChannelID + ">"
+}
@@ -0,0 +1,148 @@
+package misc
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+type ImportProgress struct {
+    // Number of channels and threads whose native pins have been collected
+    Channels int
+
+    // Number of native pins found, queued, skipped for already being pinned, and unpinned afterwards
+    Found int
+    Queued int
+    Skipped int
+    Unpinned int
+}
+
+// ImportPins collects the native pins of every text channel and thread, and queues them to the default board in
+// chronological order. Messages that are already pinned are skipped. If unpin is set, native pins are removed afterwards,
+// freeing up space below Discord's limit of 50 pins per channel. Progress is called after every channel.
+func ImportPins(discord *discordgo.Session, guild_id string, unpin bool, progress func(*ImportProgress)) (*ImportProgress, error) {
+    if !startScan(guild_id) {
+        return nil, SCAN_RUNNING
+    }
+    defer doneScan(guild_id)
+
+    db := database.Connect()
+    c := db.GetConfig(guild_id)
+
+    channels, err := importChannels(discord, guild_id)
+    if err != nil {
+        return nil, err
+    }
+
+    p := &ImportProgress{}
+    var pins []*discordgo.Message
+    for _, channel_id := range channels {
+        if c.IsPinChannel(channel_id) {
+            continue
+        }
+
+        found, err := discord.ChannelMessagesPinned(channel_id)
+        if err != nil {
+            log.Printf("Failed to fetch pins of channel '%s': %v", channel_id, err)
+            continue
+        }
+        for _, message := range found {
+            message.GuildID = guild_id
+        }
+        pins = append(pins, found...)
+
+        p.Channels += 1
+        p.Found += len(found)
+        if progress != nil {
+            progress(p)
+        }
+    }
+
+    // Pin in the order the messages were sent, regardless of channel
+    slices.SortStableFunc(pins, func(a, b *discordgo.Message) int {
+        return a.Timestamp.Compare(b.Timestamp)
+    })
+
+    for _, message := range pins {
+        if _, _, err := db.GetPin(guild_id, "", message.ID); err == nil {
+            p.Skipped += 1
+        } else {
+            req, err := CreatePinRequest(discord, guild_id, "", message)
+            if err != nil {
+                log.Printf("Failed to create pin request for message '%s': %v", message.ID, err)
+                continue
+            }
+            Queue.Push(req)
+            p.Queued += 1
+        }
+
+        // The queued request holds a copy of the message, so the native pin is no longer needed
+        if unpin {
+            if err := UnpinNative(discord, message.ChannelID, message.ID); err != nil {
+                log.Printf("Failed to unpin message '%s': %v", message.ID, err)
+                continue
+            }
+            p.Unpinned += 1
+        }
+    }
+
+    return p, nil
+}
+
+// importChannels returns the id of every text channel of a guild, followed by every active and archived thread in them
+func importChannels(discord *discordgo.Session, guild_id string) ([]string, error) {
+    all, err := discord.GuildChannels(guild_id)
+    if err != nil {
+        return nil, err
+    }
+
+    var channels, parents []string
+    for _, channel := range all {
+        switch channel.Type {
+            case discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews:
+                channels = append(channels, channel.ID)
+                parents = append(parents, channel.ID)
+            case discordgo.ChannelTypeGuildForum:
+                parents = append(parents, channel.ID)
+        }
+    }
+
+    active, err := discord.GuildThreadsActive(guild_id)
+    if err != nil {
+        log.Printf("Failed to fetch active threads of guild '%s': %v", guild_id, err)
+    } else {
+        for _, thread := range active.Threads {
+            channels = append(channels, thread.ID)
+        }
+    }
+
+    // Page through the archived threads of each channel, private ones only if the bot is allowed to see them
+    for _, parent := range parents {
+        for _, list := range []func(string, *time.Time, int, ...discordgo.RequestOption) (*discordgo.ThreadsList, error){
+            discord.ThreadsArchived,
+            discord.ThreadsPrivateArchived,
+        } {
+            var before *time.Time
+            for {
+                archived, err := list(parent, before, 100)
+                if err != nil || len(archived.Threads) == 0 {
+                    break
+                }
+                for _, thread := range archived.Threads {
+                    channels = append(channels, thread.ID)
+                    if thread.ThreadMetadata != nil {
+                        before = &thread.ThreadMetadata.ArchiveTimestamp
+                    }
+                }
+                if !archived.HasMore || before == nil {
+                    break
+                }
+            }
+        }
+    }
+
+    return channels, nil
+}
@@ -0,0 +1,66 @@
+package misc
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Time after which a native unpin by the bot is forgotten, if its pin update never came
+var UNPIN_MARK_TIMEOUT = time.Minute
+
+// unpinMark is a message being natively unpinned by the bot, in the channel it is in
+type unpinMark struct {
+    channelID string
+    at time.Time
+}
+
+// Map of message ids being natively unpinned by the bot itself -> channel and time of the unpin
+// Used to tell these apart from members unpinning messages
+var unpinning = make(map[string]*unpinMark)
+var unpinningMu sync.Mutex
+
+// UnpinNative removes the native pin of a message, marking it as unpinned by the bot
+func UnpinNative(discord *discordgo.Session, channel_id string, message_id string) error {
+    unpinningMu.Lock()
+    for id, m := range unpinning {
+        if time.Since(m.at) > UNPIN_MARK_TIMEOUT {
+            delete(unpinning, id)
+        }
+    }
+    unpinning[message_id] = &unpinMark{ channelID: channel_id, at: time.Now() }
+    unpinningMu.Unlock()
+
+    err := discord.ChannelMessageUnpin(channel_id, message_id)
+    if err != nil {
+        unpinningMu.Lock()
+        delete(unpinning, message_id)
+        unpinningMu.Unlock()
+    }
+    return err
+}
+
+// UnpinnedByBot returns whether a message was recently natively unpinned by the bot, forgetting it if so
+func UnpinnedByBot(message_id string) bool {
+    unpinningMu.Lock()
+    defer unpinningMu.Unlock()
+
+    m, ok := unpinning[message_id]
+    delete(unpinning, message_id)
+    return ok && time.Since(m.at) <= UNPIN_MARK_TIMEOUT
+}
+
+// ForgetUnpinned forgets the native unpins by the bot in a channel that have taken effect, given its current pins
+// Called on every pin update of the channel, whether or not the unpins could be told apart from its previous pins
+func ForgetUnpinned(channel_id string, current []string) {
+    unpinningMu.Lock()
+    defer unpinningMu.Unlock()
+
+    for id, m := range unpinning {
+        if m.channelID == channel_id && !slices.Contains(current, id) {
+            delete(unpinning, id)
+        }
+    }
+}
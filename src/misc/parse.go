@@ -1,12 +1,19 @@
 package misc
 
 import (
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 
 	emoji "github.com/Andrew-M-C/go.emoji"
 	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
 )
 
+// Matches optional weight (*2) and threshold (=5) modifiers at the end of a token
+var emojiModifiers = regexp.MustCompile(`(?:\*(\d+))?(?:=(\d+))?$`)
+
 // ExtractEmojis returns an identifier for each emoji in the given string
 func ExtractEmojis(text string) []string {
     var res []string
@@ -29,6 +36,67 @@ func ExtractEmojis(text string) []string {
     return slices.Compact(res)
 }
 
+// ParseEmojiRules returns a rule for each emoji in the given string
+// Emojis may be followed by a weight and/or threshold, e.g. "⭐ 💯*2 🏆=2",
+// which applies to every emoji in the same whitespace-separated token
+func ParseEmojiRules(text string) database.Allowlist {
+    rules := make(database.Allowlist)
+
+    for _, token := range strings.Fields(text) {
+        var rule database.EmojiRule
+
+        // Split modifiers from the emojis they apply to
+        if m := emojiModifiers.FindStringSubmatchIndex(token); m != nil && m[0] < len(token) {
+            if m[2] >= 0 {
+                rule.Weight, _ = strconv.Atoi(token[m[2]:m[3]])
+            }
+            if m[4] >= 0 {
+                rule.Threshold, _ = strconv.Atoi(token[m[4]:m[5]])
+            }
+            token = token[:m[0]]
+        }
+
+        for _, e := range ExtractEmojis(token) {
+            rules[e] = rule
+        }
+    }
+
+    return rules
+}
+
+// ParseForumTags returns a map of emoji -> forum tag name from the given comma-separated list,
+// where each entry starts with an emoji followed by the name of the tag, e.g. "⭐ Starred, 🔥 Hot"
+func ParseForumTags(text string) map[string]string {
+    tags := make(map[string]string)
+
+    for _, entry := range strings.Split(text, ",") {
+        fields := strings.Fields(entry)
+        if len(fields) < 2 {
+            continue
+        }
+        name := strings.Join(fields[1:], " ")
+        for _, e := range ExtractEmojis(fields[0]) {
+            tags[e] = name
+        }
+    }
+
+    return tags
+}
+
+// FormatEmoji returns the message format of an emoji identifier returned by ExtractEmojis
+// Identifiers leave out whether custom emojis are animated, which is only known for emojis of the given guild
+func FormatEmoji(discord *discordgo.Session, guild_id string, api_name string) string {
+    // Custom emojis are identified by name:id
+    name, id, ok := strings.Cut(api_name, ":")
+    if !ok {
+        return api_name
+    }
+    if e, err := discord.State.Emoji(guild_id, id); err == nil {
+        return e.MessageFormat()
+    }
+    return "<:" + name + ":" + id + ">"
+}
+
 // GetMessageLink returns a URL for the given message
 func GetMessageLink(guild_id string, channel_id string, message_id string) string {
     return discordgo.EndpointDiscord + "channels/" + guild_id + "/" + channel_id + "/" + message_id
@@ -29,75 +29,130 @@ var (
 
 type PinRequest struct {
     guildID string
+    board string
     message *discordgo.Message
     reference *PinRequest
+
+    // Whether the message replies to a message that was deleted
+    referenceDeleted bool
+
+    // Messages sent before the message in its channel, shown as context of its pin, oldest first
+    context []*discordgo.Message
+
+    // Messages pinned together with the message as one conversation, starting with it (if any)
+    conversation []*discordgo.Message
+
+    // Map of message id -> fields of the message available to templates, built once per request
+    data map[string]*TemplateData
+
+    // Thread the pin is sent into while executing, and the name of the forum post to create for it (if any)
+    thread string
+    threadName string
 }
 
-// Hashset of messages currently being pinned
+// Hashset of board and message ids currently being pinned
 // Helps prevent rapid reactions from pinning a message twice
-var pinning = make(map[string]struct{})
+var pinning = make(map[[2]string]struct{})
 var pinningMu sync.Mutex
 
-// startPinning marks a message as currently being pinned.
+// startPinning marks a message as currently being pinned to a board.
 // Returns false if it was already being pinned.
-func startPinning(messageID string) bool {
+func startPinning(board string, messageID string) bool {
     pinningMu.Lock()
     defer pinningMu.Unlock()
 
-    if _, ok := pinning[messageID]; ok {
+    if _, ok := pinning[[2]string{ board, messageID }]; ok {
         return false
     }
-    pinning[messageID] = struct{}{}
+    pinning[[2]string{ board, messageID }] = struct{}{}
     return true
 }
 
-// donePinning removes a message from the currently-pinning set.
-func donePinning(messageID string) {
+// donePinning removes a message from the currently-pinning set of a board.
+func donePinning(board string, messageID string) {
     pinningMu.Lock()
-    delete(pinning, messageID)
+    delete(pinning, [2]string{ board, messageID })
     pinningMu.Unlock()
 }
 
 // CreatePinRequest creates a copy of the message, and all messages it references, in its current state
-func CreatePinRequest(discord *discordgo.Session, guild_id string, message *discordgo.Message) (*PinRequest, error) {
+// The message is pinned to the named board, or the default board if the name is ""
+func CreatePinRequest(discord *discordgo.Session, guild_id string, board string, message *discordgo.Message) (*PinRequest, error) {
     // Skip messages that cannot feasibly be pinned
     if _, ok := VALID_MSG_TYPE[message.Type]; !ok {
         return nil, fmt.Errorf("This type of message cannot be pinned")
     }
 
     // Skip messages currently being pinned
-    if !startPinning(message.ID) {
+    if !startPinning(board, message.ID) {
         return nil, ALREADY_PINNED
     }
 
-    // Retrieve current config
-    db := database.Connect()
-    c := db.GetConfig(guild_id)
+    // Retrieve current config, with any overrides of the message's channel
+    c, _ := GetChannelConfig(discord, guild_id, board, message.ChannelID)
 
-    // Create pin request
-    req := &PinRequest{ guildID: guild_id, message: message }
+    // Pin what forwarded messages forward, rather than their empty content, along with any stickers and poll
+    message = expandMessage(unwrapForward(discord, guild_id, message))
 
-    if c.ReplyDepth > 0 && message.MessageReference != nil {
-        // Iteratively create pin requests for any messages this one references
-        curr, depth := req, 0
-        for ref := message.MessageReference; ref != nil && depth < c.ReplyDepth; ref = req.reference.message.MessageReference {
-            // Fetch message that is being referenced
-            ref_msg, err := discord.ChannelMessage(ref.ChannelID, ref.MessageID)
-            if err != nil {
-                log.Printf("Failed to fetch referenced message #%d: %v", depth, err)
-                break
-            }
+    // Create pin request, with the messages leading up to this one
+    req := &PinRequest{ guildID: guild_id, board: board, message: message }
+    req.context = fetchContext(discord, c, message)
+
+    // Create pin requests for the messages this one replies to, following the chain up to the reply depth
+    seen := map[string]struct{}{ message.ID: {} }
+    curr := req
+    for depth := 0; depth < c.ReplyDepth; depth++ {
+        ref := curr.message.MessageReference
+        if ref == nil || ref.MessageID == "" || ref.Type == discordgo.MessageReferenceTypeForward {
+            break
+        }
+
+        // Messages of other guilds cannot be pinned here
+        if ref.GuildID != "" && ref.GuildID != guild_id {
+            break
+        }
 
-            // Create pin request for said message
+        // Stop at messages already in the chain
+        if _, ok := seen[ref.MessageID]; ok {
+            log.Printf("Reply chain of message '%s' loops at message '%s'", message.ID, ref.MessageID)
+            break
+        }
+        seen[ref.MessageID] = struct{}{}
+
+        // Link to messages that are already pinned, rather than pinning them and their own replies again
+        // Only the id of such a message is needed to find its pin
+        if _, _, err := database.Connect().GetPin(guild_id, board, ref.MessageID); err == nil {
             curr.reference = &PinRequest{
                 guildID: guild_id,
-                message: ref_msg,
+                board: board,
+                message: &discordgo.Message{ ID: ref.MessageID, ChannelID: ref.ChannelID },
             }
+            break
+        }
 
-            // Move pointer
-            curr = curr.reference
-            depth += 1
+        // Fetch message that is being referenced, which may be in another channel or thread
+        channel_id := ref.ChannelID
+        if channel_id == "" {
+            channel_id = curr.message.ChannelID
         }
+        ref_msg, err := discord.ChannelMessage(channel_id, ref.MessageID)
+        if err != nil {
+            if isDeletedError(err) {
+                log.Printf("Message '%s' replies to deleted message '%s'", curr.message.ID, ref.MessageID)
+                curr.referenceDeleted = true
+            } else {
+                log.Printf("Failed to fetch referenced message #%d: %v", depth, err)
+            }
+            break
+        }
+
+        // Create pin request for said message, expanded like the message being pinned, and move on to the message it references
+        curr.reference = &PinRequest{
+            guildID: guild_id,
+            board: board,
+            message: expandMessage(unwrapForward(discord, guild_id, ref_msg)),
+        }
+        curr = curr.reference
     }
 
     log.Printf("Created new pin request for message '%s' in guild '%s'", message.ID, guild_id)
@@ -107,12 +162,12 @@ func CreatePinRequest(discord *discordgo.Session, guild_id string, message *disc
 // Execute on a PinRequest pins the message, forwarding it to the pin channel
 // Returns the used pin channel ID and pin message's ID if successful
 func (req *PinRequest) Execute(discord *discordgo.Session) (string, string, error) {
-    defer donePinning(req.message.ID)
+    defer donePinning(req.board, req.message.ID)
 
     db := database.Connect()
 
     // Query database for if message is already pinned
-    pin_channel_id, pin_msg_id, err := db.GetPin(req.guildID, req.message.ID)
+    pin_channel_id, pin_msg_id, err := db.GetPin(req.guildID, req.board, req.message.ID)
 
     // Only throw up error if it's an actual error (not just row not found)
     if err != nil && err != sql.ErrNoRows {
@@ -125,87 +180,257 @@ func (req *PinRequest) Execute(discord *discordgo.Session) (string, string, erro
     }
 
     // Create base webhook params
+    params := req.webhookParams(discord, req.message)
+
+    // If the message being pinned is a reply, pin the referenced message first
+    ref_link := ""
+    if req.reference != nil {
+        ref_pin_channel_id, ref_pin_msg_id, _ := req.reference.Execute(discord)
+        if ref_pin_channel_id != "" && ref_pin_msg_id != "" {
+            ref_link = GetMessageLink(req.guildID, ref_pin_channel_id, ref_pin_msg_id)
+        }
+    }
+
+    // Get the current webhook of the pin channel this message is routed to, unless pins are sent as embeds
+    c, _ := GetChannelConfig(discord, req.guildID, req.board, req.message.ChannelID)
+    dest := req.setDestination(discord, c)
+
+    var webhook *discordgo.Webhook
+    if c.Render != database.RENDER_EMBED {
+        webhook, err = GetWebhook(discord, req.guildID, c.Channel)
+        if err != nil {
+            if !isPermissionError(err) {
+                return "", "", fmt.Errorf("Failed to retrieve webhook: %v", err)
+            }
+            log.Printf("Missing permission to create webhooks in channel '%s', sending pin as an embed instead", c.Channel)
+        }
+    }
+
+    // Send every message for this pin, so it can be retracted later
+    var parts []*database.PinMessage
+    if len(req.conversation) > 0 {
+        // The board may have changed since the conversation was queued
+        if webhook == nil {
+            return "", "", CONVERSATION_EMBED
+        }
+        parts, err = req.sendConversation(discord, webhook, params)
+    } else if webhook != nil && c.Layout == database.LAYOUT_COMPACT {
+        parts, err = req.sendCompact(discord, webhook, params, ref_link)
+    } else if webhook != nil {
+        parts, err = req.sendWebhook(discord, webhook, params, ref_link)
+    } else {
+        parts, err = req.sendEmbed(discord, c.Channel, params, ref_link)
+    }
+    if err != nil {
+        req.discard(discord, parts)
+        return "", "", err
+    }
+
+    // The message that stands for the pin is the copy of the message itself
+    var pin_msg *database.PinMessage
+    for _, p := range parts {
+        if p.Kind == database.PART_BODY || p.Kind == database.PART_EMBED || p.Kind == database.PART_COMPACT {
+            pin_msg = p
+            break
+        }
+    }
+
+    // Tag the forum post after the emoji it was pinned with
+    if req.threadName != "" && req.thread != "" && dest != nil {
+        if tags := forumTags(c, dest, req.message); len(tags) > 0 {
+            if _, err := discord.ChannelEdit(req.thread, &discordgo.ChannelEdit{ AppliedTags: &tags }); err != nil {
+                log.Printf("Failed to tag forum post '%s': %v", req.thread, err)
+            }
+        }
+    }
+    forum_thread := ""
+    if req.threadName != "" {
+        forum_thread = req.thread
+    }
+
+    // Copy reactions from original message if possible
+    for _, r := range req.message.Reactions {
+        discord.MessageReactionAdd(pin_msg.ChannelID, pin_msg.MessageID, r.Emoji.APIName())
+    }
+
+    // Add pin message to database
+    // Without a record, the pin could neither be retracted nor recognized when retried, so undo it
+    err = req.record(pin_msg, parts, forum_thread)
+    if err != nil {
+        req.discard(discord, parts)
+        db.RemovePin(req.guildID, req.board, req.message.ID)
+        return "", "", err
+    }
+
+    log.Printf("Pinned message '%s' to board '%s' in guild '%s'", req.message.ID, req.board, req.guildID)
+    return pin_msg.ChannelID, pin_msg.MessageID, nil
+}
+
+// record adds the pin, every message sent for it and its context to the database
+func (req *PinRequest) record(pin_msg *database.PinMessage, parts []*database.PinMessage, forum_thread string) error {
+    db := database.Connect()
+
+    err := db.AddPin(req.guildID, req.board, pin_msg.ChannelID, req.message.ID, pin_msg.MessageID, forum_thread)
+    if err != nil {
+        return fmt.Errorf("Failed to add pin to database: %v", err)
+    }
+    for _, p := range parts {
+        err = db.AddPinMessage(req.guildID, req.board, req.message.ID, p)
+        if err != nil {
+            return fmt.Errorf("Failed to add pin message to database: %v", err)
+        }
+    }
+    err = db.AddPinContext(req.guildID, req.board, req.message.ChannelID, req.message.ID, contextIDs(req.context))
+    if err != nil {
+        return fmt.Errorf("Failed to add pin context to database: %v", err)
+    }
+    return nil
+}
+
+// discard deletes the messages sent for a pin that could not be completed, along with the forum post created for it
+// (if any), so retrying the pin does not leave copies of it behind
+func (req *PinRequest) discard(discord *discordgo.Session, parts []*database.PinMessage) {
+    if req.threadName != "" && req.thread != "" {
+        _, err := discord.ChannelDelete(req.thread)
+        if err == nil {
+            return
+        }
+        log.Printf("Failed to delete forum post '%s': %v", req.thread, err)
+    }
+    deletePinMessages(discord, parts)
+}
+
+// webhookParams returns the base webhook params for copies of a message of this pin, impersonating its author
+func (req *PinRequest) webhookParams(discord *discordgo.Session, message *discordgo.Message) *discordgo.WebhookParams {
+    guild_id := req.guildID
     params := &discordgo.WebhookParams{
-        Username: "Unknown",
+        Username: usernameContent(req.templateData(discord, message)),
         AvatarURL: "",
 
         // Disable pinging
         AllowedMentions: &discordgo.MessageAllowedMentions{},
     }
-    if a := req.message.Author; a != nil {
-        if member, err := discord.GuildMember(req.guildID, a.ID); err == nil {
-            params.Username = GetName(member)
+    if a := message.Author; a != nil {
+        if member, err := discord.GuildMember(guild_id, a.ID); err == nil {
             params.AvatarURL = member.AvatarURL("")
         }
     }
+    return params
+}
 
-    // If the message being pinned is a reply, pin the referenced message first
-    ref_pin_channel_id, ref_pin_msg_id := "", ""
-    if req.reference != nil {
-        ref_pin_channel_id, ref_pin_msg_id, _ = req.reference.Execute(discord)
-    }
+// sendWebhook sends the pin as copies of the message through a webhook, between a header linking to the pin of the
+// message it replies to (if any) and a footer tallying its reactions
+func (req *PinRequest) sendWebhook(discord *discordgo.Session, webhook *discordgo.Webhook, params *discordgo.WebhookParams, ref_link string) ([]*database.PinMessage, error) {
+    var parts []*database.PinMessage
 
-    // Get the current webhook
-    webhook, err := GetWebhook(discord, req.guildID)
-    if err != nil {
-        return "", "", fmt.Errorf("Failed to retrieve webhook: %v", err)
+    // Quote the messages leading up to this one (if any)
+    if len(req.context) > 0 {
+        params.Content = contextContent(discord, req.guildID, req.context, MAX_CONTENT)
+        context, err := req.send(discord, webhook, params)
+        if err != nil {
+            return parts, fmt.Errorf("Failed to send context: %v", err)
+        }
+        parts = append(parts, &database.PinMessage{ ChannelID: context.ChannelID, MessageID: context.ID, Kind: database.PART_CONTEXT, WebhookID: webhook.ID })
     }
 
-    // Send formatted link to pinned referenced message (if there is one)
-    if ref_pin_channel_id != "" && ref_pin_msg_id != "" {
-        params.Content = "-# ╰ Reply to " + GetMessageLink(req.guildID, ref_pin_channel_id, ref_pin_msg_id)
-        _, err = discord.WebhookExecute(webhook.ID, webhook.Token, true, params)
+    // Send formatted link to pinned referenced message, or the thread the message is in (if either)
+    if content := headerContent(req.templateData(discord, req.message), ref_link, req.referenceDeleted, 0); content != "" {
+        params.Content = content
+        header, err := req.send(discord, webhook, params)
         if err != nil {
-            return "", "", fmt.Errorf("Failed to send reference header: %v", err)
+            return parts, fmt.Errorf("Failed to send reference header: %v", err)
         }
+        parts = append(parts, &database.PinMessage{ ChannelID: header.ChannelID, MessageID: header.ID, Kind: database.PART_HEADER, WebhookID: webhook.ID })
     }
 
-    // Send the webhook copy to the pin channel
-    pin_msg, err := req.cloneMessage(discord, webhook, params)
+    // Send the webhook copy to the pin channel, keeping whatever was sent even if it fails partway
+    pin_msg, att_msgs, err := req.cloneMessage(discord, webhook, params)
+    if pin_msg != nil {
+        parts = append(parts, &database.PinMessage{ ChannelID: pin_msg.ChannelID, MessageID: pin_msg.ID, Kind: database.PART_BODY, WebhookID: webhook.ID })
+    }
+    for _, m := range att_msgs {
+        parts = append(parts, &database.PinMessage{ ChannelID: m.ChannelID, MessageID: m.ID, Kind: database.PART_ATTACHMENT, WebhookID: webhook.ID })
+    }
     if err != nil {
-        return "", "", fmt.Errorf("Failed to clone pin message: %v", err)
+        return parts, fmt.Errorf("Failed to clone pin message: %v", err)
     }
 
     // Send footer
-    params.Content = "-# " + GetMessageLink(req.guildID, req.message.ChannelID, req.message.ID) + " " + req.message.Author.Mention()
-    _, err = discord.WebhookExecute(webhook.ID, webhook.Token, true, params)
+    params.Content = footerContent(req.templateData(discord, req.message))
+    footer, err := req.send(discord, webhook, params)
     if err != nil {
-        return "", "", fmt.Errorf("Failed to send pin footer: %v", err)
+        return parts, fmt.Errorf("Failed to send pin footer: %v", err)
     }
+    parts = append(parts, &database.PinMessage{ ChannelID: footer.ChannelID, MessageID: footer.ID, Kind: database.PART_FOOTER, WebhookID: webhook.ID })
 
-    // Copy reactions from original message if possible
-    for _, r := range req.message.Reactions {
-        discord.MessageReactionAdd(pin_msg.ChannelID, pin_msg.ID, r.Emoji.APIName())
-    }
+    return parts, nil
+}
 
-    // Add pin message to database
-    c := db.GetConfig(req.guildID)
-    err = db.AddPin(req.guildID, c.Channel, req.message.ID, pin_msg.ID)
+// setDestination sets the thread this pin is sent into, or the forum post created for it, returning the pin channel
+// Pins are sent into the configured thread of the pin channel, or to forums each in their own post
+func (req *PinRequest) setDestination(discord *discordgo.Session, c *database.Config) *discordgo.Channel {
+    req.thread, req.threadName = c.Thread, ""
+    dest, err := getChannel(discord, c.Channel)
     if err != nil {
-        return "", "", fmt.Errorf("Failed to add pin to database: %v", err)
+        return nil
     }
+    if dest.Type == discordgo.ChannelTypeGuildForum && req.thread == "" {
+        req.threadName = forumTitle(req.message, req.templateData(discord, req.message))
+    }
+    return dest
+}
 
-    log.Printf("Pinned message '%s' in guild '%s'", req.message.ID, req.guildID)
-    return pin_msg.ChannelID, pin_msg.ID, nil
+// templateData returns the fields of a message of this pin available to templates, building them only once
+// as they take several lookups of members and channels
+func (req *PinRequest) templateData(discord *discordgo.Session, message *discordgo.Message) *TemplateData {
+    if data, ok := req.data[message.ID]; ok {
+        return data
+    }
+    if req.data == nil {
+        req.data = make(map[string]*TemplateData)
+    }
+    req.data[message.ID] = templateData(discord, req.guildID, req.board, message)
+    return req.data[message.ID]
+}
+
+// send executes a webhook for this pin, inside the thread the pin is sent into (if any)
+// If the pin needs a forum post, the first message sent creates it
+func (req *PinRequest) send(discord *discordgo.Session, webhook *discordgo.Webhook, params *discordgo.WebhookParams) (*discordgo.Message, error) {
+    // Messages made of components (compact pins) are only accepted when asked for
+    var options []discordgo.RequestOption
+    if params.Flags & discordgo.MessageFlagsIsComponentsV2 != 0 {
+        options = append(options, withComponents())
+    }
+
+    if req.thread != "" {
+        return discord.WebhookThreadExecute(webhook.ID, webhook.Token, true, req.thread, params, options...)
+    }
+    if req.threadName == "" {
+        return discord.WebhookExecute(webhook.ID, webhook.Token, true, params, options...)
+    }
+
+    post := *params
+    post.ThreadName = req.threadName
+    msg, err := discord.WebhookExecute(webhook.ID, webhook.Token, true, &post, options...)
+    if err != nil {
+        return nil, err
+    }
+    req.thread = msg.ChannelID
+    return msg, nil
 }
 
 // cloneMessage recreates the given message into the given webhook with the given base parameters
-// Returns the message object that the webhook sent (not including header/footer/attachment messages)
-func (req *PinRequest) cloneMessage(discord *discordgo.Session, webhook *discordgo.Webhook, base *discordgo.WebhookParams) (*discordgo.Message, error) {
+// Returns the message object that the webhook sent, and any additional attachment messages sent after it
+func (req *PinRequest) cloneMessage(discord *discordgo.Session, webhook *discordgo.Webhook, base *discordgo.WebhookParams) (*discordgo.Message, []*discordgo.Message, error) {
     var pin_msg *discordgo.Message
+    var att_msgs []*discordgo.Message
     skip := false
 
     // Create copy of message as webhook parameters
     params := *base
     params.Content = req.message.Content
     params.Components = req.message.Components
-
-    // Only copy rich embeds, not embeds from links (Discord will add them itself)
-    for _, e := range req.message.Embeds {
-        if e.Type == discordgo.EmbedTypeRich {
-            params.Embeds = append(params.Embeds, e)
-        }
-    }
+    params.Embeds = richEmbeds(req.message)
 
     // Append as many attachments to webhook that can fit
     var file_sets [][]*discordgo.File
@@ -216,7 +441,7 @@ func (req *PinRequest) cloneMessage(discord *discordgo.Session, webhook *discord
         // Get file upload size limit of guild
         size_limit, err := sizeLimit(discord, webhook.GuildID)
         if err != nil {
-            return nil, err
+            return nil, nil, err
         }
 
         // Split files based on this size limit
@@ -244,9 +469,9 @@ func (req *PinRequest) cloneMessage(discord *discordgo.Session, webhook *discord
     // Send the webhook copy to the pin channel
     if !skip {
         var err error
-        pin_msg, err = discord.WebhookExecute(webhook.ID, webhook.Token, true, &params)
+        pin_msg, err = req.send(discord, webhook, &params)
         if err != nil {
-            return nil, err
+            return nil, nil, err
         }
     }
 
@@ -271,22 +496,40 @@ func (req *PinRequest) cloneMessage(discord *discordgo.Session, webhook *discord
 
         // Send attachment message
         if att.Files != nil || att.Content != "" {
-            att_msg, err := discord.WebhookExecute(webhook.ID, webhook.Token, true, &att)
+            att_msg, err := req.send(discord, webhook, &att)
             if err != nil {
-                return nil, err
+                return pin_msg, att_msgs, err
             }
 
             // If pin message was skipped, set pin message to first attachment message
             if skip {
                 pin_msg = att_msg
                 skip = false
+            } else {
+                att_msgs = append(att_msgs, att_msg)
             }
         } else {
             break
         }
     }
 
-    return pin_msg, nil
+    // Nothing could be sent at all
+    if pin_msg == nil {
+        return nil, nil, fmt.Errorf("Message has no content that can be copied")
+    }
+
+    return pin_msg, att_msgs, nil
+}
+
+// richEmbeds returns only the rich embeds of a message, not embeds from links (Discord will add them itself)
+func richEmbeds(message *discordgo.Message) []*discordgo.MessageEmbed {
+    var embeds []*discordgo.MessageEmbed
+    for _, e := range message.Embeds {
+        if e.Type == discordgo.EmbedTypeRich {
+            embeds = append(embeds, e)
+        }
+    }
+    return embeds
 }
 
 // splitAttachments splits a list of attachments into list of lists of attachments, each sublist under the size limit
@@ -1,13 +1,46 @@
 package misc
 
 import (
-    "sync"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
 )
 
+var (
+    // Maximum number of times a pin request is attempted before it is dropped
+    MAX_ATTEMPTS = 5
+
+    // Time waited before the first retry of a failed pin request, doubled for every retry after
+    RETRY_DELAY = 30 * time.Second
+)
+
+// queuedRequest is a pin request along with its row in the queue table
+type queuedRequest struct {
+    id int64
+    attempts int
+    req *PinRequest
+}
+
+// PinQueue holds pending pin requests of each guild, executed by a pool of workers
+// Requests of one guild are executed strictly in order, while different guilds are executed in parallel
 type PinQueue struct {
-    queue []*PinRequest
+    // Map of guild id -> pending pin requests
+    queues map[string][]*queuedRequest
+
+    // Guilds with pending requests that no worker is executing, in the order they became ready
+    ready []string
+
+    // Hashset of guilds a worker is currently executing a request of, or that are waiting to retry one
+    busy map[string]struct{}
+
+    closed bool
+    workers sync.WaitGroup
     lock *sync.Mutex
     cond *sync.Cond
 }
@@ -15,37 +48,248 @@ var Queue *PinQueue
 
 func NewQueue() *PinQueue {
     q := &PinQueue{}
-    q.queue = make([]*PinRequest, 0, 10)
+    q.queues = make(map[string][]*queuedRequest)
+    q.busy = make(map[string]struct{})
     q.lock = &sync.Mutex{}
     q.cond = sync.NewCond(q.lock)
     return q
 }
 
+// Push stores a pin request in the database, so it survives restarts, and queues it
 func (q *PinQueue) Push(req *PinRequest) {
+    db := database.Connect()
+    id, err := db.AddQueued(req.guildID, req.board, req.message.ChannelID, req.message.ID, req.lastID())
+    if err != nil {
+        // Still attempt the pin, it just won't survive a restart
+        log.Printf("Failed to store pin request for message '%s': %v", req.message.ID, err)
+    }
+
+    q.push(&queuedRequest{ id: id, req: req })
+}
+
+// push appends an item to the queue of its guild
+func (q *PinQueue) push(item *queuedRequest) {
     q.lock.Lock()
     defer q.lock.Unlock()
 
     // Append to queue
-    q.queue = append(q.queue, req)
+    guild_id := item.req.guildID
+    q.queues[guild_id] = append(q.queues[guild_id], item)
 
-    // Signal new change
-    q.cond.Signal()
+    // Guild becomes ready if it had nothing pending and no worker is on it
+    // Otherwise, it is marked ready again once its worker is done
+    if _, ok := q.busy[guild_id]; !ok && len(q.queues[guild_id]) == 1 {
+        q.ready = append(q.ready, guild_id)
+
+        // Signal new change
+        q.cond.Signal()
+    }
 }
 
-func (q *PinQueue) Execute(discord *discordgo.Session) (string, string, error)  {
+// Load queues every pin request stored in the database, fetching their messages again
+// Called on startup, before the session opens, to resume requests that were pending when the bot stopped
+func (q *PinQueue) Load(discord *discordgo.Session) error {
+    db := database.Connect()
+
+    queued, err := db.GetQueued()
+    if err != nil {
+        return fmt.Errorf("Failed to load queued pin requests: %v", err)
+    }
+
+    held := make(map[string]struct{})
+    for _, row := range queued {
+        req, err := loadRequest(discord, row.GuildID, row.Board, row.ChannelID, row.MessageID, row.LastID)
+        if err != nil {
+            log.Printf("Dropping queued pin request for message '%s': %v", row.MessageID, err)
+            db.RemoveQueued(row.ID)
+            continue
+        }
+
+        // Wait out any backoff that was pending before the restart, holding back the rest of the guild's requests
+        // Only the first request of each guild is waited on, as the ones after it cannot run before it anyway
+        item := &queuedRequest{ id: row.ID, attempts: row.Attempts, req: req }
+        q.push(item)
+        if _, ok := held[row.GuildID]; !ok {
+            held[row.GuildID] = struct{}{}
+            if delay := time.Until(row.NextAttempt); delay > 0 {
+                q.hold(row.GuildID, delay)
+            }
+        }
+    }
+
+    log.Printf("Loaded %d queued pin requests", len(queued))
+    return nil
+}
+
+// Start launches the given number of workers, each executing pin requests until the queue is stopped
+func (q *PinQueue) Start(discord *discordgo.Session, workers int) {
+    for range workers {
+        q.workers.Add(1)
+        go func() {
+            defer q.workers.Done()
+            for {
+                item := q.pop()
+                if item == nil {
+                    return
+                }
+
+                // Execute pin request
+                _, _, err := item.req.Execute(discord)
+                if err != nil && !errors.Is(err, ALREADY_PINNED) {
+                    log.Printf("Error thrown during queue pop: %v", err)
+                }
+                if delay, retry := q.finish(item, err); retry {
+                    q.retry(item, delay)
+                } else {
+                    q.done(item.req.guildID)
+                }
+            }
+        }()
+    }
+    log.Printf("Started %d pin workers", workers)
+}
+
+// Stop prevents workers from taking new pin requests, and waits for requests in progress to finish
+// Requests still pending are kept in the database, and resumed on the next startup
+func (q *PinQueue) Stop() {
     q.lock.Lock()
+    q.closed = true
+    q.cond.Broadcast()
+    q.lock.Unlock()
 
-    // Block if queue is empty
-    for len(q.queue) == 0 {
+    q.workers.Wait()
+}
+
+// pop takes the next pin request of the guild that has been ready the longest, marking the guild busy
+// Blocks until a request is ready, returning nil once the queue is stopped
+func (q *PinQueue) pop() *queuedRequest {
+    q.lock.Lock()
+    defer q.lock.Unlock()
+
+    // Block if no guild is ready
+    for len(q.ready) == 0 && !q.closed {
         q.cond.Wait()
     }
+    if q.closed {
+        return nil
+    }
 
-    // Pop from queue
-    top, rest := q.queue[0], q.queue[1:]
-    q.queue = rest
+    // Pop guild from ready list, and request from its queue
+    guild_id := q.ready[0]
+    q.ready = q.ready[1:]
+
+    top := q.queues[guild_id][0]
+    q.queues[guild_id] = q.queues[guild_id][1:]
+    if len(q.queues[guild_id]) == 0 {
+        delete(q.queues, guild_id)
+    }
 
+    q.busy[guild_id] = struct{}{}
+    return top
+}
+
+// done marks a guild as no longer busy, making it ready again if it has pending requests
+func (q *PinQueue) done(guild_id string) {
+    q.lock.Lock()
+    defer q.lock.Unlock()
+
+    delete(q.busy, guild_id)
+    if len(q.queues[guild_id]) > 0 {
+        q.ready = append(q.ready, guild_id)
+        q.cond.Signal()
+    }
+}
+
+// retry puts a failed pin request back at the front of its guild's queue, keeping the guild busy until the delay is
+// over, so pins within one guild stay strictly ordered even when one of them has to wait for a retry
+func (q *PinQueue) retry(item *queuedRequest, delay time.Duration) {
+    q.lock.Lock()
+    guild_id := item.req.guildID
+    q.queues[guild_id] = append([]*queuedRequest{ item }, q.queues[guild_id]...)
     q.lock.Unlock()
 
-    // Execute pin request
-    return top.Execute(discord)
+    time.AfterFunc(delay, func() { q.done(guild_id) })
+}
+
+// hold marks a guild busy for a while, so none of its requests are executed until then
+func (q *PinQueue) hold(guild_id string, delay time.Duration) {
+    q.lock.Lock()
+    q.busy[guild_id] = struct{}{}
+    q.ready = slices.DeleteFunc(q.ready, func(g string) bool { return g == guild_id })
+    q.lock.Unlock()
+
+    time.AfterFunc(delay, func() { q.done(guild_id) })
+}
+
+// finish removes an executed pin request from the database, or records when to retry it if it failed
+// Returns the delay before the retry, and whether the request should be retried
+func (q *PinQueue) finish(item *queuedRequest, err error) (time.Duration, bool) {
+    db := database.Connect()
+
+    // Messages that were already pinned need no retry
+    if err == nil || errors.Is(err, ALREADY_PINNED) {
+        if err := db.RemoveQueued(item.id); err != nil {
+            log.Printf("Failed to remove pin request from queue: %v", err)
+        }
+        return 0, false
+    }
+
+    item.attempts += 1
+    if item.attempts >= MAX_ATTEMPTS {
+        log.Printf("Giving up on pin request for message '%s' after %d attempts", item.req.message.ID, item.attempts)
+
+        // Keep the request around so admins can see why it failed, and retry it
+        req := item.req
+        if err := db.AddFailure(req.guildID, req.board, req.message.ChannelID, req.message.ID, req.lastID(), item.attempts, err.Error()); err != nil {
+            log.Printf("Failed to record failed pin request: %v", err)
+        }
+        if err := db.RemoveQueued(item.id); err != nil {
+            log.Printf("Failed to remove pin request from queue: %v", err)
+        }
+        return 0, false
+    }
+
+    // Keep the message marked as being pinned until it is retried, unless a new request has taken over
+    if !startPinning(item.req.board, item.req.message.ID) {
+        db.RemoveQueued(item.id)
+        return 0, false
+    }
+
+    // Retry with exponential backoff
+    delay := RETRY_DELAY << (item.attempts - 1)
+    if err := db.RetryQueued(item.id, item.attempts, time.Now().Add(delay)); err != nil {
+        log.Printf("Failed to update pin request in queue: %v", err)
+    }
+    log.Printf("Retrying pin request for message '%s' in %v", item.req.message.ID, delay)
+    return delay, true
+}
+
+// Retry queues a pin request that was given up on again, removing it from the failures table
+func (q *PinQueue) Retry(discord *discordgo.Session, f *database.FailedPin) error {
+    req, err := loadRequest(discord, f.GuildID, f.Board, f.ChannelID, f.MessageID, f.LastID)
+    if err != nil {
+        return err
+    }
+    q.Push(req)
+
+    db := database.Connect()
+    return db.RemoveFailure(f.ID)
+}
+
+// loadRequest creates a stored pin request again, fetching its messages in their current state
+// Requests with a last message pin the conversation from the message up to it
+func loadRequest(discord *discordgo.Session, guild_id string, board string, channel_id string, message_id string, last_id string) (*PinRequest, error) {
+    if last_id != "" {
+        messages, err := fetchConversation(discord, channel_id, message_id, last_id)
+        if err != nil {
+            return nil, err
+        }
+        return CreateConversationRequest(discord, guild_id, board, messages)
+    }
+
+    message, err := discord.ChannelMessage(channel_id, message_id)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to fetch message '%s': %v", message_id, err)
+    }
+    return CreatePinRequest(discord, guild_id, board, message)
 }
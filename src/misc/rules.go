@@ -0,0 +1,138 @@
+package misc
+
+import (
+	"log"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Map of message id to reaction emoji API name
+// Used to prevent self-pinning (if that is disabled)
+// Cached to reduce API calls
+var selfpin = make(map[string]map[string]struct{})
+var selfpinMu sync.RWMutex
+
+// AddSelfReaction records that the author of a message reacted to it with an emoji
+func AddSelfReaction(message_id string, emoji string) {
+    selfpinMu.Lock()
+    defer selfpinMu.Unlock()
+
+    if selfpin[message_id] == nil {
+        selfpin[message_id] = make(map[string]struct{})
+    }
+    selfpin[message_id][emoji] = struct{}{}
+}
+
+// RemoveSelfReaction records that the author of a message removed their reaction of an emoji
+func RemoveSelfReaction(message_id string, emoji string) {
+    selfpinMu.Lock()
+    defer selfpinMu.Unlock()
+
+    if selfpin[message_id] != nil {
+        delete(selfpin[message_id], emoji)
+    }
+}
+
+// HasSelfReactions returns whether the author of a message has ever been seen reacting to it
+func HasSelfReactions(message_id string) bool {
+    selfpinMu.RLock()
+    defer selfpinMu.RUnlock()
+
+    _, ok := selfpin[message_id]
+    return ok
+}
+
+// ForgetSelfReactions removes a message from the selfpin map
+func ForgetSelfReactions(message_id string) {
+    selfpinMu.Lock()
+    defer selfpinMu.Unlock()
+
+    delete(selfpin, message_id)
+}
+
+// hasSelfReaction returns whether the author of a message reacted to it with an emoji
+func hasSelfReaction(message_id string, emoji string) bool {
+    selfpinMu.RLock()
+    defer selfpinMu.RUnlock()
+
+    _, ok := selfpin[message_id][emoji]
+    return ok
+}
+
+// ShouldPin checks all reactions of the messsage, and determines if the message should be pinned.
+func ShouldPin(discord *discordgo.Session, c *database.Config, message *discordgo.Message) bool {
+    sum := 0
+    var allowed []*discordgo.MessageReactions
+
+    for _, r := range message.Reactions {
+        // Ignore reactions not in the allowlist (if it is non-empty)
+        if !c.Allowlist.Allows(r.Emoji.APIName()) {
+            continue
+        }
+        allowed = append(allowed, r)
+
+        count := r.Count
+
+        // Remove reactions from the message author from the count
+        if !c.Selfpin {
+            if hasSelfReaction(message.ID, r.Emoji.APIName()) {
+                count--
+            }
+        }
+
+        // Pin messages with any reactions worth geq the threshold (of that emoji)
+        points := count * c.Allowlist.Weight(r.Emoji.APIName())
+        if points >= c.Allowlist.Threshold(r.Emoji.APIName(), c.Threshold) {
+            return true
+        }
+        sum += points
+    }
+
+    if c.CountMode != database.COUNT_SUM || sum < c.Threshold {
+        return false
+    }
+
+    // The sum of points may include members that reacted with several emojis,
+    // so only pin if the points of unique members reach the threshold
+    return countReactors(discord, c, message, allowed) >= c.Threshold
+}
+
+// countReactors returns the points of unique users that reacted to a message with any of the given reactions
+// Users that reacted with several emojis are only worth the points of their most valuable emoji
+func countReactors(discord *discordgo.Session, c *database.Config, message *discordgo.Message, reactions []*discordgo.MessageReactions) int {
+    users := make(map[string]int)
+
+    for _, r := range reactions {
+        weight := c.Allowlist.Weight(r.Emoji.APIName())
+
+        // Page through every user that reacted with this emoji
+        after := ""
+        for {
+            page, err := discord.MessageReactions(message.ChannelID, message.ID, r.Emoji.APIName(), 100, "", after)
+            if err != nil {
+                log.Printf("Failed to fetch reactions of message '%s': %v", message.ID, err)
+                break
+            }
+            for _, u := range page {
+                users[u.ID] = max(users[u.ID], weight)
+            }
+            if len(page) < 100 {
+                break
+            }
+            after = page[len(page)-1].ID
+        }
+    }
+
+    // Remove the message author from the count
+    if !c.Selfpin && message.Author != nil {
+        delete(users, message.Author.ID)
+    }
+
+    points := 0
+    for _, weight := range users {
+        points += weight
+    }
+    return points
+}
@@ -0,0 +1,168 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+var (
+    // Time to wait after the last reaction before updating a tally
+    TALLY_DELAY = 5 * time.Second
+
+    // Maximum number of emojis shown in a tally
+    TALLY_MAX_EMOJIS = 5
+)
+
+// Hashmap of message id -> pending tally update
+// Used to debounce many reactions in quick succession into one edit
+var tallies = make(map[string]*time.Timer)
+var talliesMu sync.Mutex
+
+// RefreshTally schedules an update of the reaction tally in the footer of a message's pin
+func RefreshTally(discord *discordgo.Session, guild_id string, channel_id string, message_id string) {
+    talliesMu.Lock()
+    defer talliesMu.Unlock()
+
+    // Push back pending update if there is one
+    if timer, ok := tallies[message_id]; ok {
+        timer.Reset(TALLY_DELAY)
+        return
+    }
+
+    tallies[message_id] = time.AfterFunc(TALLY_DELAY, func() {
+        talliesMu.Lock()
+        delete(tallies, message_id)
+        talliesMu.Unlock()
+
+        err := updateTally(discord, guild_id, channel_id, message_id)
+        if err != nil {
+            log.Printf("Failed to update tally for message '%s': %v", message_id, err)
+        }
+    })
+}
+
+// updateTally edits the footer of every pin of a message to reflect the message's current reactions
+func updateTally(discord *discordgo.Session, guild_id string, channel_id string, message_id string) error {
+    db := database.Connect()
+
+    boards, err := db.GetPinBoards(guild_id, message_id)
+    if err != nil {
+        return fmt.Errorf("Failed to fetch boards: %v", err)
+    }
+    if len(boards) == 0 {
+        return nil
+    }
+
+    message, err := discord.ChannelMessage(channel_id, message_id)
+    if err != nil {
+        return fmt.Errorf("Failed to fetch message: %v", err)
+    }
+    message = unwrapForward(discord, guild_id, message)
+
+    for _, board := range boards {
+        parts, err := db.GetPinMessages(guild_id, board, message_id)
+        if err != nil {
+            return fmt.Errorf("Failed to fetch pin messages: %v", err)
+        }
+
+        // Find the footer of the pin, which embed and compact pins hold in the same message as everything else
+        var footer *database.PinMessage
+        for _, p := range parts {
+            if p.Kind == database.PART_FOOTER || p.Kind == database.PART_EMBED || p.Kind == database.PART_COMPACT {
+                footer = p
+            }
+        }
+        if footer == nil {
+            continue
+        }
+        content := footerContent(templateData(discord, guild_id, board, message))
+
+        // Compact pins hold the footer in the last text of their container
+        if footer.Kind == database.PART_COMPACT {
+            err = editCompact(discord, footer, func(container *discordgo.Container, _ *discordgo.Message) {
+                if texts := compactTexts(container); len(texts) > 0 {
+                    texts[len(texts)-1].Content = content
+                }
+            })
+            if err != nil {
+                return fmt.Errorf("Failed to edit pin footer: %v", err)
+            }
+            continue
+        }
+
+        // Embed pins are sent by the bot itself
+        if footer.Kind == database.PART_EMBED {
+            _, err = discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+                ID: footer.MessageID,
+                Channel: footer.ChannelID,
+                Content: &content,
+
+                // Disable pinging
+                AllowedMentions: &discordgo.MessageAllowedMentions{},
+            })
+            if err != nil {
+                return fmt.Errorf("Failed to edit pin footer: %v", err)
+            }
+            continue
+        }
+
+        // Pins made before every message was recorded cannot be updated
+        if footer.WebhookID == "" {
+            continue
+        }
+
+        // Only the webhook that sent a message can edit it
+        webhook, err := GetWebhookByID(discord, footer.WebhookID)
+        if err != nil {
+            return err
+        }
+
+        _, err = discord.WebhookMessageEdit(webhook.ID, webhook.Token, footer.MessageID, &discordgo.WebhookEdit{
+            Content: &content,
+
+            // Disable pinging
+            AllowedMentions: &discordgo.MessageAllowedMentions{},
+        }, inThread(webhook, footer)...)
+        if err != nil {
+            return fmt.Errorf("Failed to edit pin footer: %v", err)
+        }
+    }
+    return nil
+}
+
+// footerContent returns the footer sent after a pin on a board, linking to the message and tallying its reactions
+func footerContent(data *TemplateData) string {
+    return renderTemplate(data.config.Templates.Footer, DEFAULT_FOOTER, data)
+}
+
+// reactionTally returns a summary of the reactions on a message that can pin it to a board (e.g. "⭐ 14 · 🔥 6")
+func reactionTally(discord *discordgo.Session, guild_id string, board string, message *discordgo.Message) string {
+    c, _ := GetChannelConfig(discord, guild_id, board, message.ChannelID)
+
+    var reactions []*discordgo.MessageReactions
+    for _, r := range message.Reactions {
+        // If allowlist is non-empty, only count allowed emojis
+        if !c.Allowlist.Allows(r.Emoji.APIName()) {
+            continue
+        }
+        reactions = append(reactions, r)
+    }
+
+    // Most popular emojis first
+    slices.SortStableFunc(reactions, func(a, b *discordgo.MessageReactions) int {
+        return b.Count - a.Count
+    })
+
+    var tally []string
+    for _, r := range reactions[:min(len(reactions), TALLY_MAX_EMOJIS)] {
+        tally = append(tally, fmt.Sprintf("%s %d", r.Emoji.MessageFormat(), r.Count))
+    }
+    return strings.Join(tally, " · ")
+}
@@ -0,0 +1,210 @@
+package misc
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Templates used when a guild has not set its own
+const (
+    DEFAULT_HEADER = `-# ╰ {{if .Conversation}}Conversation of {{.Conversation}} messages in {{or .ThreadMention .ChannelMention}}` +
+        `{{if or .Forwarded .Reply .ReplyDeleted}} · {{end}}{{else if .Thread}}In {{.ThreadMention}}{{if or .Forwarded .Reply .ReplyDeleted}} · {{end}}{{end}}` +
+        `{{if .Forwarded}}Forwarded from {{.Forwarded}}{{else if .Reply}}Reply to {{.Reply}}{{else if .ReplyDeleted}}Reply to a deleted message{{end}}`
+    DEFAULT_FOOTER = `-# {{.Link}} {{.AuthorMention}}{{if .Reactions}} · {{.Reactions}}{{end}}`
+    DEFAULT_USERNAME = `{{.Author}}`
+)
+
+// Maximum length of a webhook username
+const MAX_USERNAME = 80
+
+// TemplateData holds the fields available to header, footer and username templates
+type TemplateData struct {
+    // Display name and mention of the author of the message
+    Author string
+    AuthorMention string
+
+    // Name and mention of the channel the message was sent in (the parent channel of threads)
+    Channel string
+    ChannelMention string
+
+    // Name and mention of the thread the message was sent in, if any
+    Thread string
+    ThreadMention string
+
+    // Link to the message, and to the pin of the message it replies to (header only)
+    Link string
+    Reply string
+
+    // Whether the message replies to a message that was deleted (header only)
+    ReplyDeleted bool
+
+    // Mention of the channel a forwarded message was forwarded from (or a link to the original, if in another server)
+    Forwarded string
+
+    // Number of messages pinned together, if the message starts a conversation (header only)
+    Conversation int
+
+    // Tally of allowed reactions (e.g. "⭐ 14 · 🔥 6"), their total count, and the most used one
+    Reactions string
+    Count int
+    Emoji string
+
+    // Time the message was sent, and the board it is pinned to ("" for the default board)
+    Date time.Time
+    Board string
+
+    // Config of the board for the channel of the message, holding the templates to fill
+    config *database.Config
+}
+
+// templateData returns the fields of a message available to templates for a board
+func templateData(discord *discordgo.Session, guild_id string, board string, message *discordgo.Message) *TemplateData {
+    c, _ := GetChannelConfig(discord, guild_id, board, message.ChannelID)
+
+    data := &TemplateData{
+        Author: "Unknown",
+        ChannelMention: "<#" + message.ChannelID + ">",
+        Link: GetMessageLink(guild_id, message.ChannelID, message.ID),
+        Forwarded: forwardedFrom(guild_id, message),
+        Reactions: reactionTally(discord, guild_id, board, message),
+        Emoji: topReaction(c, message),
+        Date: message.Timestamp,
+        Board: board,
+        config: c,
+    }
+
+    if a := message.Author; a != nil {
+        data.AuthorMention = a.Mention()
+        data.Author = memberName(discord, guild_id, a)
+    }
+    if channel, err := getChannel(discord, message.ChannelID); err == nil {
+        data.Channel = channel.Name
+        if channel.IsThread() {
+            data.Thread, data.ThreadMention = channel.Name, channel.Mention()
+            data.ChannelMention = "<#" + channel.ParentID + ">"
+            data.Channel = ""
+            if parent, err := getChannel(discord, channel.ParentID); err == nil {
+                data.Channel = parent.Name
+            }
+        }
+    }
+    for _, r := range message.Reactions {
+        if c.Allowlist.Allows(r.Emoji.APIName()) {
+            data.Count += r.Count
+        }
+    }
+
+    return data
+}
+
+// memberName returns the name a user goes by in a guild, preferring the cached member
+func memberName(discord *discordgo.Session, guild_id string, user *discordgo.User) string {
+    if user == nil {
+        return "Unknown"
+    }
+    if member, err := discord.State.Member(guild_id, user.ID); err == nil {
+        return GetName(member)
+    } else if member, err := discord.GuildMember(guild_id, user.ID); err == nil {
+        return GetName(member)
+    }
+    return user.Username
+}
+
+// executeTemplate fills a template with the given data
+func executeTemplate(text string, data *TemplateData) (string, error) {
+    t, err := template.New("").Option("missingkey=error").Parse(text)
+    if err != nil {
+        return "", err
+    }
+
+    var out bytes.Buffer
+    if err := t.Execute(&out, data); err != nil {
+        return "", err
+    }
+    return strings.TrimSpace(out.String()), nil
+}
+
+// renderTemplate fills a guild's template with the given data, falling back to the default template if it is unset,
+// broken, or empty or too long once filled, as Discord rejects such messages
+func renderTemplate(text string, fallback string, data *TemplateData) string {
+    if text != "" {
+        out, err := executeTemplate(text, data)
+        if err == nil && out != "" && len([]rune(out)) <= MAX_CONTENT {
+            return out
+        }
+        if err != nil {
+            log.Printf("Failed to render template %q, using default: %v", text, err)
+        } else if out != "" {
+            log.Printf("Template %q is too long once filled, using default", text)
+        }
+    }
+
+    // Even the default may be too long, given long enough names
+    out, _ := executeTemplate(fallback, data)
+    if runes := []rune(out); len(runes) > MAX_CONTENT {
+        out = string(runes[:MAX_CONTENT-1]) + "…"
+    }
+    return out
+}
+
+// headerContent returns the header sent before a pin on a board, linking to the pin of the message it replies to
+// (or noting that it was deleted), naming the thread it was sent in and where it was forwarded from,
+// and counting the messages of the conversation it starts (0 if it is pinned alone), or "" if there is nothing to say
+func headerContent(message_data *TemplateData, ref_link string, ref_deleted bool, conversation int) string {
+    data := *message_data
+    data.Reply, data.ReplyDeleted, data.Conversation = ref_link, ref_deleted, conversation
+    if data.Reply == "" && !data.ReplyDeleted && data.Thread == "" && data.Forwarded == "" && data.Conversation == 0 {
+        return ""
+    }
+    return renderTemplate(data.config.Templates.Header, DEFAULT_HEADER, &data)
+}
+
+// usernameContent returns the name the webhook impersonating the author of a message uses on a board
+func usernameContent(data *TemplateData) string {
+    name := []rune(renderTemplate(data.config.Templates.Username, DEFAULT_USERNAME, data))
+    if len(name) > MAX_USERNAME {
+        name = name[:MAX_USERNAME]
+    }
+    if len(name) == 0 {
+        return "Unknown"
+    }
+    return string(name)
+}
+
+// PreviewTemplate validates a template, returning it filled with example data
+func PreviewTemplate(text string, author string, channel string) (string, error) {
+    data := &TemplateData{
+        Author: author,
+        AuthorMention: "@" + author,
+        Channel: channel,
+        ChannelMention: "#" + channel,
+        Thread: "thread",
+        ThreadMention: "#thread",
+        Link: discordgo.EndpointDiscord + "channels/0/0/0",
+        Reply: discordgo.EndpointDiscord + "channels/0/0/1",
+        Reactions: "⭐ 14 · 🔥 6",
+        Count: 20,
+        Emoji: "⭐",
+        Date: time.Now(),
+        Board: "",
+    }
+
+    out, err := executeTemplate(text, data)
+    if err != nil {
+        return "", err
+    }
+    if out == "" {
+        return "", fmt.Errorf("Template is empty once filled")
+    }
+    if len([]rune(out)) > MAX_CONTENT {
+        return "", fmt.Errorf("Template is longer than %d characters once filled", MAX_CONTENT)
+    }
+    return out, nil
+}
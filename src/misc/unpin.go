@@ -0,0 +1,71 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jadc/redpin/database"
+)
+
+// Unpin retracts the pin of a message from a board, deleting every message sent for it from the pin channel
+// and removing it, along with its statistics, from the database
+func Unpin(discord *discordgo.Session, guild_id string, board string, message_id string) error {
+    db := database.Connect()
+
+    parts, err := db.GetPinMessages(guild_id, board, message_id)
+    if err != nil {
+        return fmt.Errorf("Failed to fetch pin messages for message '%s': %v", message_id, err)
+    }
+
+    // Pins made before every message was recorded only know of their body
+    if len(parts) == 0 {
+        pin_channel_id, pin_msg_id, err := db.GetPin(guild_id, board, message_id)
+        if err != nil {
+            return fmt.Errorf("Failed to fetch pin id for message '%s': %v", message_id, err)
+        }
+        parts = append(parts, &database.PinMessage{ ChannelID: pin_channel_id, MessageID: pin_msg_id, Kind: database.PART_BODY })
+    }
+
+    // Pins sent to forums are deleted along with their post
+    if thread_id, err := db.GetPinThread(guild_id, board, message_id); err == nil && thread_id != "" {
+        if _, err := discord.ChannelDelete(thread_id); err == nil {
+            parts = nil
+        } else {
+            log.Printf("Failed to delete forum post '%s': %v", thread_id, err)
+        }
+    }
+
+    deletePinMessages(discord, parts)
+
+    err = db.RemovePin(guild_id, board, message_id)
+    if err != nil {
+        return fmt.Errorf("Failed to remove pin from database: %v", err)
+    }
+
+    err = db.RemoveStats(guild_id, board, message_id)
+    if err != nil {
+        return fmt.Errorf("Failed to remove statistics from database: %v", err)
+    }
+
+    log.Printf("Unpinned message '%s' from board '%s' in guild '%s'", message_id, board, guild_id)
+    return nil
+}
+
+// deletePinMessages deletes messages sent to the pin channel, continuing past any already deleted
+func deletePinMessages(discord *discordgo.Session, parts []*database.PinMessage) {
+    for _, p := range parts {
+        // Prefer deleting through the webhook that sent the message, as it needs no permissions
+        if p.WebhookID != "" {
+            if webhook, err := GetWebhookByID(discord, p.WebhookID); err == nil {
+                if err = discord.WebhookMessageDelete(webhook.ID, webhook.Token, p.MessageID, inThread(webhook, p)...); err == nil {
+                    continue
+                }
+            }
+        }
+
+        if err := discord.ChannelMessageDelete(p.ChannelID, p.MessageID); err != nil {
+            log.Printf("Failed to delete pin message '%s': %v", p.MessageID, err)
+        }
+    }
+}
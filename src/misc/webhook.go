@@ -2,8 +2,10 @@ package misc
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
     "log"
+    "net/http"
     "sync"
 
 	"github.com/bwmarrin/discordgo"
@@ -21,54 +23,60 @@ type WebhookPair struct {
     LRU bool
 }
 
-// Hashmap of guild id -> pair of webhook ids
+// Hashmap of pin channel id -> pair of webhook ids
 // Used to prevent many pins in quick succession from being merged into one message
 // Technically, if the bot is restarted, the LRU bit is reset and
 // messages may get merged, but this is a very unlikely scenario
 var webhooks = make(map[string]*WebhookPair)
 var webhooksMu sync.Mutex
 
-// GetWebhook returns the appropriate webhook for a given guild
-func GetWebhook(discord *discordgo.Session, guild_id string) (*discordgo.Webhook, error) {
-    webhooksMu.Lock()
-    defer webhooksMu.Unlock()
-
-    db := database.Connect()
-    c := db.GetConfig(guild_id)
+// webhookRefusal is the error the bot was refused with when creating webhooks in a pin channel of a guild
+type webhookRefusal struct {
+    guildID string
+    err error
+}
 
-    if pair, ok := webhooks[guild_id]; ok {
-		// Recreate cached webhooks if pin channel has changed
-        if pair.WebhookA.ChannelID != c.Channel || pair.WebhookB.ChannelID != c.Channel {
-            discord.WebhookDelete(pair.WebhookA.ID)
-            discord.WebhookDelete(pair.WebhookB.ID)
+// Hashmap of pin channel id -> refusal to create webhooks in it, guarded by webhooksMu
+// Pins to these channels fall back to embeds without asking again, until permissions in their guild change
+var webhookRefusals = make(map[string]*webhookRefusal)
 
-            var err error
-            pair, err = createWebhook(discord, guild_id, c.Channel)
-            if err != nil {
-                return nil, err
-            }
-        }
+// GetWebhook returns the appropriate webhook for a given pin channel in a guild
+func GetWebhook(discord *discordgo.Session, guild_id string, channel_id string) (*discordgo.Webhook, error) {
+    webhooksMu.Lock()
+    defer webhooksMu.Unlock()
 
-		// Otherwise, use other webhook in cached pair
+    // Use other webhook in cached pair
+    if pair, ok := webhooks[channel_id]; ok {
         return alternateWebhook(pair), nil
     }
 
-	// Fetch webhook pair from database if not cached
-    webhook_a_id, webhook_b_id, err := db.GetWebhook(guild_id)
+    // Don't ask for webhooks again where the bot was not allowed to create them
+    if r, ok := webhookRefusals[channel_id]; ok {
+        return nil, r.err
+    }
+
+    // Fetch webhook pair from database if not cached
+    db := database.Connect()
+    webhook_a_id, webhook_b_id, err := db.GetWebhook(channel_id)
     if err != nil && err != sql.ErrNoRows {
-        return nil, fmt.Errorf("Failed to retrieve webhook pair for guild '%s': %v", guild_id, err)
+        return nil, fmt.Errorf("Failed to retrieve webhook pair for channel '%s': %v", channel_id, err)
+    }
+
+    // Adopt the pair stored for the whole guild by older versions, if it is in this channel
+    if len(webhook_a_id) == 0 || len(webhook_b_id) == 0 {
+        webhook_a_id, webhook_b_id, _ = db.GetLegacyWebhook(guild_id)
     }
 
     var pair *WebhookPair
 
-    if err == sql.ErrNoRows || len(webhook_a_id) == 0 || len(webhook_b_id) == 0 {
-		// If no webhook pair in database, create new one
-        pair, err = createWebhook(discord, guild_id, c.Channel)
+    if len(webhook_a_id) == 0 || len(webhook_b_id) == 0 {
+        // If no webhook pair in database, create new one
+        pair, err = createWebhook(discord, guild_id, channel_id)
         if err != nil {
             return nil, err
         }
     } else {
-		// If webhook pair in databse, fetch webhook object and cache it
+        // If webhook pair in databse, fetch webhook object and cache it
         var webhook_a *discordgo.Webhook
         var webhook_b *discordgo.Webhook
         webhook_a, err = discord.Webhook(webhook_a_id)
@@ -76,21 +84,47 @@ func GetWebhook(discord *discordgo.Session, guild_id string) (*discordgo.Webhook
             webhook_b, err = discord.Webhook(webhook_b_id)
         }
 
-        if err != nil {
-            // If stored webhook IDs are stale/deleted, create new pair
-            pair, err = createWebhook(discord, guild_id, c.Channel)
+        if err != nil || webhook_a.ChannelID != channel_id || webhook_b.ChannelID != channel_id {
+            // If stored webhook IDs are stale/deleted/elsewhere, create new pair
+            pair, err = createWebhook(discord, guild_id, channel_id)
             if err != nil {
                 return nil, err
             }
         } else {
             pair = &WebhookPair{ WebhookA: webhook_a, WebhookB: webhook_b }
-            webhooks[guild_id] = pair
+            if err = db.SetWebhook(guild_id, channel_id, webhook_a.ID, webhook_b.ID); err != nil {
+                log.Printf("Failed to add webhook to database: %v", err)
+            }
+            webhooks[channel_id] = pair
         }
     }
 
     return alternateWebhook(pair), nil
 }
 
+// GetWebhookByID returns the webhook with the given ID, preferring cached webhook pairs
+func GetWebhookByID(discord *discordgo.Session, webhook_id string) (*discordgo.Webhook, error) {
+    webhooksMu.Lock()
+    for _, pair := range webhooks {
+        if pair.WebhookA.ID == webhook_id {
+            webhooksMu.Unlock()
+            return pair.WebhookA, nil
+        }
+        if pair.WebhookB.ID == webhook_id {
+            webhooksMu.Unlock()
+            return pair.WebhookB, nil
+        }
+    }
+    webhooksMu.Unlock()
+
+    // Webhooks of pin channels not used since startup are not cached
+    webhook, err := discord.Webhook(webhook_id)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to retrieve webhook '%s': %v", webhook_id, err)
+    }
+    return webhook, nil
+}
+
 // alternateWebhook flips the LRU bit and returns the next webhook in the pair.
 func alternateWebhook(pair *WebhookPair) *discordgo.Webhook {
     pair.LRU = !pair.LRU
@@ -107,23 +141,101 @@ func createWebhook(discord *discordgo.Session, guild_id string, channel_id strin
     // Create webhook A in given channel
     webhookA, err := discord.WebhookCreate(channel_id, "redpin A", "")
     if err != nil {
-        return nil, fmt.Errorf("Failed to create webhook A in channel '%s': %v", channel_id, err)
+        err = fmt.Errorf("Failed to create webhook A in channel '%s': %w", channel_id, err)
+        if isPermissionError(err) {
+            webhookRefusals[channel_id] = &webhookRefusal{ guildID: guild_id, err: err }
+        }
+        return nil, err
     }
 
-    // Create webhook A in given channel
+    // Create webhook B in given channel, not leaving webhook A behind if it cannot be
     webhookB, err := discord.WebhookCreate(channel_id, "redpin B", "")
     if err != nil {
-        return nil, fmt.Errorf("Failed to create webhook B in channel '%s': %v", channel_id, err)
+        deleteWebhooks(discord, webhookA)
+        err = fmt.Errorf("Failed to create webhook B in channel '%s': %w", channel_id, err)
+        if isPermissionError(err) {
+            webhookRefusals[channel_id] = &webhookRefusal{ guildID: guild_id, err: err }
+        }
+        return nil, err
     }
 
-    err = db.SetWebhook(guild_id, webhookA.ID, webhookB.ID)
+    err = db.SetWebhook(guild_id, channel_id, webhookA.ID, webhookB.ID)
     if err != nil {
+        deleteWebhooks(discord, webhookA, webhookB)
         return nil, fmt.Errorf("Failed to add webhook to database: %v", err)
     }
 
     // Cache new webhook pair
-    webhooks[guild_id] = &WebhookPair{ WebhookA: webhookA, WebhookB: webhookB }
-    log.Printf("Created new webhook pair for guild '%s'", guild_id)
+    webhooks[channel_id] = &WebhookPair{ WebhookA: webhookA, WebhookB: webhookB }
+    log.Printf("Created new webhook pair for channel '%s' in guild '%s'", channel_id, guild_id)
+
+    return webhooks[channel_id], nil
+}
+
+// deleteWebhooks deletes webhooks that were created but cannot be used
+func deleteWebhooks(discord *discordgo.Session, unused ...*discordgo.Webhook) {
+    for _, w := range unused {
+        if err := discord.WebhookDelete(w.ID); err != nil {
+            log.Printf("Failed to delete unused webhook '%s': %v", w.ID, err)
+        }
+    }
+}
+
+// ForgetWebhookRefusals lets the bot try creating webhooks again in the pin channels of a guild where it was refused,
+// as its permissions there may have changed
+func ForgetWebhookRefusals(guild_id string) {
+    webhooksMu.Lock()
+    defer webhooksMu.Unlock()
 
-    return webhooks[guild_id], nil
+    for channel_id, r := range webhookRefusals {
+        if r.guildID == guild_id {
+            delete(webhookRefusals, channel_id)
+        }
+    }
+}
+
+// isPermissionError returns whether an error was caused by the bot missing permissions
+func isPermissionError(err error) bool {
+    var rest *discordgo.RESTError
+    if !errors.As(err, &rest) {
+        return false
+    }
+    if rest.Message != nil && rest.Message.Code == discordgo.ErrCodeMissingPermissions {
+        return true
+    }
+    return rest.Response != nil && rest.Response.StatusCode == http.StatusForbidden
+}
+
+// inThread returns the options to reach a message sent by a webhook, which must name the thread
+// the message is in if it isn't in the channel of the webhook (e.g. in a forum post)
+func inThread(webhook *discordgo.Webhook, part *database.PinMessage) []discordgo.RequestOption {
+    if part.ChannelID == "" || part.ChannelID == webhook.ChannelID {
+        return nil
+    }
+    return []discordgo.RequestOption{
+        func(cfg *discordgo.RequestConfig) {
+            query := cfg.Request.URL.Query()
+            query.Set("thread_id", part.ChannelID)
+            cfg.Request.URL.RawQuery = query.Encode()
+        },
+    }
+}
+
+// withComponents returns the option letting a webhook message carry components, which Discord otherwise drops
+// (or rejects, for messages made only of components)
+func withComponents() discordgo.RequestOption {
+    return func(cfg *discordgo.RequestConfig) {
+        query := cfg.Request.URL.Query()
+        query.Set("with_components", "true")
+        cfg.Request.URL.RawQuery = query.Encode()
+    }
+}
+
+// isDeletedError returns whether a request failed because the message or channel it was about no longer exists
+func isDeletedError(err error) bool {
+    var rest *discordgo.RESTError
+    if !errors.As(err, &rest) || rest.Message == nil {
+        return false
+    }
+    return rest.Message.Code == discordgo.ErrCodeUnknownMessage || rest.Message.Code == discordgo.ErrCodeUnknownChannel
 }